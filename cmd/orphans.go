@@ -0,0 +1,33 @@
+// Copyright 2018 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/fairwindsops/rbac-lookup/lookup"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(orphansCmd)
+}
+
+var orphansCmd = &cobra.Command{
+	Use:   "orphans [subject query]",
+	Short: "List bindings to ServiceAccounts or namespaces that no longer exist",
+	Args:  cobra.RangeArgs(0, 1),
+	Run: func(cmd *cobra.Command, args []string) {
+		lookup.Orphans(args, listOptions())
+	},
+}
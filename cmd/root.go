@@ -24,37 +24,132 @@ import (
 )
 
 var (
-	version      string
-	commit       string
-	outputFormat string
-	enableGke    bool
-	kubeConfig   string
-	kubeContext  string
-	subjectKind  string
+	version        string
+	commit         string
+	outputFormat   string
+	enableGke      bool
+	enableEks      bool
+	enableAks      bool
+	aadDirectory   []string
+	kubeConfig     string
+	kubeContext    string
+	subjectKind    string
+	sortBy         string
+	showRules      bool
+	checkRefs      bool
+	matchMode      string
+	ignoreCase     bool
+	queryFile      string
+	role           string
+	selector       string
+	exclude        []string
+	excludeSystem  bool
+	implicitGroups bool
+	groupMapFile   string
+	namespaces     []string
+	allNamespaces  bool
+	namespacedOnly bool
 )
 
 var rootCmd = &cobra.Command{
-	Use:   "rbac-lookup [subject query]",
+	Use:   "rbac-lookup [subject query...]",
 	Short: "rbac-lookup provides a simple way to view RBAC bindings by user",
 	Long:  "rbac-lookup provides a missing Kubernetes API to view RBAC bindings by user",
-	Args:  cobra.RangeArgs(0, 1),
+	Args:  cobra.ArbitraryArgs,
 	Run: func(cmd *cobra.Command, args []string) {
 		if err := cmd.ParseFlags(args); err != nil {
 			fmt.Printf("Error parsing flags: %v\n", err)
 		}
 
-		subjectKind = strings.ToLower(subjectKind)
-
-		lookup.List(args, kubeConfig, kubeContext, outputFormat, subjectKind, enableGke)
+		opts := listOptions()
+		opts.QueryFile = queryFile
+		lookup.List(args, opts)
 	},
 }
 
 func init() {
-	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", "", "output format (normal, wide)")
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", "", "output format (normal, wide, json, yaml, csv, tsv, roles, bindings, go-template=..., go-template-file=..., jsonpath=..., custom-columns=...)")
 	rootCmd.PersistentFlags().StringVarP(&kubeConfig, "kubeconfig", "", "", "config file location")
 	rootCmd.PersistentFlags().StringVarP(&kubeContext, "context", "", "", "context to use for Kubernetes config")
 	rootCmd.PersistentFlags().StringVarP(&subjectKind, "kind", "k", "", "filter by this RBAC subject kind (user, group, serviceaccount)")
+	rootCmd.PersistentFlags().StringVar(&matchMode, "match", "", "how the subject query is matched (contains, exact, prefix, glob, regex)")
+	rootCmd.PersistentFlags().BoolVar(&ignoreCase, "ignore-case", false, "match the subject query case insensitively")
+	rootCmd.PersistentFlags().StringVar(&role, "role", "", "only include bindings to this role, as [KIND/]NAME where NAME may be a glob")
+	rootCmd.PersistentFlags().StringVarP(&selector, "selector", "l", "", "only include bindings matching this label selector, e.g. team=payments")
+	rootCmd.PersistentFlags().StringSliceVar(&exclude, "exclude", nil, "leave out subjects matching this glob, may be repeated")
+	rootCmd.PersistentFlags().BoolVar(&excludeSystem, "exclude-system", false, "leave out system: subjects and default bindings created by Kubernetes")
+	rootCmd.PersistentFlags().BoolVar(&implicitGroups, "include-implicit-groups", false, "include roles granted through groups every ServiceAccount or user is implicitly a member of")
+	rootCmd.PersistentFlags().StringVar(&groupMapFile, "group-map", "", "YAML, JSON or CSV file mapping users to the groups they are members of")
+	rootCmd.PersistentFlags().StringSliceVarP(&namespaces, "namespace", "n", nil, "only include bindings that apply in this namespace, may be repeated")
+	rootCmd.PersistentFlags().BoolVarP(&allNamespaces, "all-namespaces", "A", false, "include bindings in every namespace, the default")
+	rootCmd.PersistentFlags().BoolVar(&namespacedOnly, "namespaced-only", false, "only list RoleBindings in the given namespaces, skipping ClusterRoleBindings")
+	rootCmd.PersistentFlags().StringVar(&sortBy, "sort-by", "", "sort output rows by subject, scope, role, or source")
+	rootCmd.PersistentFlags().BoolVar(&showRules, "show-rules", false, "show the rules granted by each bound role")
+	rootCmd.PersistentFlags().BoolVar(&checkRefs, "check-refs", false, "mark bindings that reference a missing role")
 	rootCmd.PersistentFlags().BoolVar(&enableGke, "gke", false, "enable GKE integration")
+	rootCmd.PersistentFlags().BoolVar(&enableEks, "eks", false, "enable EKS integration, resolving IAM identities mapped by aws-auth")
+	rootCmd.PersistentFlags().BoolVar(&enableAks, "aks", false, "enable AKS integration, resolving Azure AD object IDs to names")
+	rootCmd.PersistentFlags().StringSliceVar(&aadDirectory, "aad-directory", nil, "Azure AD users and groups export (JSON, YAML or CSV) used by --aks, may be repeated")
+	rootCmd.Flags().StringVar(&queryFile, "from-file", "", "read additional subject queries from this file, one per line")
+}
+
+// listOptions validates the flags shared by every command and converts them
+// into lookup options, exiting if any of them are invalid
+func listOptions() lookup.Options {
+	if err := lookup.ValidateOutputFormat(outputFormat); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if err := lookup.ValidateSortBy(sortBy); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if err := lookup.ValidateSortByFormat(sortBy, outputFormat); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if err := lookup.ValidateMatchMode(matchMode); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if allNamespaces && len(namespaces) > 0 {
+		fmt.Println("--namespace and --all-namespaces cannot be used together")
+		os.Exit(1)
+	}
+
+	if namespacedOnly && len(namespaces) == 0 {
+		fmt.Println("--namespaced-only requires --namespace")
+		os.Exit(1)
+	}
+
+	return lookup.Options{
+		KubeConfig:     kubeConfig,
+		KubeContext:    kubeContext,
+		OutputFormat:   outputFormat,
+		SubjectKind:    strings.ToLower(subjectKind),
+		SortBy:         sortBy,
+		MatchMode:      matchMode,
+		Role:           role,
+		Selector:       selector,
+		Exclude:        exclude,
+		Namespaces:     namespaces,
+		IgnoreCase:     ignoreCase,
+		ShowRules:      showRules,
+		CheckRefs:      checkRefs,
+		NamespacedOnly: namespacedOnly,
+		ExcludeSystem:  excludeSystem,
+		ImplicitGroups: implicitGroups,
+		GroupMapFile:   groupMapFile,
+		EnableGke:      enableGke,
+		EnableEks:      enableEks,
+		EnableAks:      enableAks,
+
+		AADDirectoryFiles: aadDirectory,
+	}
 }
 
 // Execute is the primary entrypoint for this CLI
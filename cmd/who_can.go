@@ -0,0 +1,40 @@
+// Copyright 2018 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/fairwindsops/rbac-lookup/lookup"
+	"github.com/spf13/cobra"
+)
+
+var whoCanSubresource string
+
+func init() {
+	whoCanCmd.Flags().StringVar(&whoCanSubresource, "subresource", "", "subresource to check, e.g. log or exec")
+	rootCmd.AddCommand(whoCanCmd)
+}
+
+var whoCanCmd = &cobra.Command{
+	Use:   "who-can VERB TYPE[.GROUP][/NAME] | VERB NONRESOURCEURL",
+	Short: "List the subjects that are allowed to perform an action",
+	Example: `  rbac-lookup who-can delete secrets -n payments
+  rbac-lookup who-can create deployments.apps
+  rbac-lookup who-can get pods --subresource log
+  rbac-lookup who-can get /metrics`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		lookup.WhoCan(args[0], args[1], whoCanSubresource, listOptions())
+	},
+}
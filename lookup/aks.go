@@ -0,0 +1,253 @@
+// Copyright 2018 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lookup
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// AKS clusters with Azure AD integration bind users and groups by their
+// object ID.
+var objectIDRegexp = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// AADIdentity is an Azure AD user, group, or service principal.
+type AADIdentity struct {
+	ObjectID          string
+	DisplayName       string
+	UserPrincipalName string
+}
+
+// Name returns the most readable name for the identity, the UPN for users
+// and the display name for anything else.
+func (id AADIdentity) Name() string {
+	if id.UserPrincipalName != "" {
+		return id.UserPrincipalName
+	}
+	return id.DisplayName
+}
+
+// AADResolver looks up Azure AD identities by object ID. Object IDs that
+// cannot be resolved are left out of the result.
+type AADResolver interface {
+	Resolve(objectIDs []string) (map[string]AADIdentity, error)
+}
+
+type aksClusterInfo struct {
+	ClusterName string
+}
+
+// getAksClusterInfo returns the name of the AKS cluster for the context, or
+// an empty name if its API server is not hosted by AKS.
+func getAksClusterInfo(c *clientcmdapi.Config, kubeContext string) *aksClusterInfo {
+	context := c.Contexts[c.CurrentContext]
+	if kubeContext != "" {
+		context = c.Contexts[kubeContext]
+	}
+
+	ci := aksClusterInfo{}
+
+	if context != nil && c.Clusters[context.Cluster] != nil {
+		server, err := url.Parse(c.Clusters[context.Cluster].Server)
+		if err == nil && strings.HasSuffix(server.Hostname(), ".azmk8s.io") {
+			ci.ClusterName = context.Cluster
+		}
+	}
+
+	return &ci
+}
+
+// loadAADIdentities resolves the object IDs of every User and Group bound,
+// so they can be shown and queried by name.
+func (l *lister) loadAADIdentities() error {
+	if l.aadResolver == nil || l.aadIdentities != nil {
+		return nil
+	}
+
+	if err := l.cacheBindings(); err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+	objectIDs := []string{}
+	addSubject := func(kind, name string) {
+		if (kind == "User" || kind == "Group") && objectIDRegexp.MatchString(name) && !seen[name] {
+			seen[name] = true
+			objectIDs = append(objectIDs, name)
+		}
+	}
+
+	for _, roleBinding := range l.roleBindings.Items {
+		for _, subject := range roleBinding.Subjects {
+			addSubject(subject.Kind, subject.Name)
+		}
+	}
+	for _, clusterRoleBinding := range l.clusterRoleBindings.Items {
+		for _, subject := range clusterRoleBinding.Subjects {
+			addSubject(subject.Kind, subject.Name)
+		}
+	}
+
+	identities, err := l.aadResolver.Resolve(objectIDs)
+	if err != nil {
+		fmt.Println("Error resolving Azure AD object IDs")
+		return err
+	}
+
+	l.aadIdentities = identities
+	if l.aadIdentities == nil {
+		l.aadIdentities = make(map[string]AADIdentity)
+	}
+
+	return nil
+}
+
+// displayName returns the resolved name of a subject bound by object ID,
+// or an empty string if it has none.
+func (l *lister) displayName(subjectName string) string {
+	return l.aadIdentities[subjectName].Name()
+}
+
+// aadDirectory resolves object IDs from directory exports, such as the
+// output of az ad user list and az ad group list.
+type aadDirectory map[string]AADIdentity
+
+// Resolve implements AADResolver.
+func (d aadDirectory) Resolve(objectIDs []string) (map[string]AADIdentity, error) {
+	identities := make(map[string]AADIdentity)
+	for _, objectID := range objectIDs {
+		if id, ok := d[strings.ToLower(objectID)]; ok {
+			identities[objectID] = id
+		}
+	}
+	return identities, nil
+}
+
+// aadDirectoryEntry accepts both the current id and the older objectId
+// field used by Azure AD exports.
+type aadDirectoryEntry struct {
+	ID                string `json:"id"`
+	ObjectID          string `json:"objectId"`
+	DisplayName       string `json:"displayName"`
+	UserPrincipalName string `json:"userPrincipalName"`
+}
+
+// NewAADDirectory reads Azure AD directory exports into a resolver. CSV
+// files need a header naming the id or objectId, displayName, and
+// userPrincipalName columns, anything else is read as a YAML or JSON list
+// of objects with those fields.
+func NewAADDirectory(paths []string) (AADResolver, error) {
+	d := aadDirectory{}
+
+	for _, path := range paths {
+		entries, err := readAADDirectory(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+
+		for _, entry := range entries {
+			objectID := entry.ID
+			if objectID == "" {
+				objectID = entry.ObjectID
+			}
+			if objectID == "" {
+				continue
+			}
+
+			d[strings.ToLower(objectID)] = AADIdentity{
+				ObjectID:          objectID,
+				DisplayName:       entry.DisplayName,
+				UserPrincipalName: entry.UserPrincipalName,
+			}
+		}
+	}
+
+	return d, nil
+}
+
+func readAADDirectory(path string) ([]aadDirectoryEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return parseAADDirectoryCSV(f)
+	}
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []aadDirectoryEntry{}
+	if err := yaml.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func parseAADDirectoryCSV(r io.Reader) ([]aadDirectoryEntry, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	idColumn, ok := columns["id"]
+	if !ok {
+		idColumn, ok = columns["objectid"]
+	}
+	if !ok {
+		return nil, fmt.Errorf("header must include an id or objectId column")
+	}
+
+	field := func(record []string, column string) string {
+		if i, ok := columns[column]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	entries := []aadDirectoryEntry{}
+	for _, record := range records[1:] {
+		entries = append(entries, aadDirectoryEntry{
+			ID:                strings.TrimSpace(record[idColumn]),
+			DisplayName:       field(record, "displayname"),
+			UserPrincipalName: field(record, "userprincipalname"),
+		})
+	}
+
+	return entries, nil
+}
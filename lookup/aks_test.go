@@ -0,0 +1,126 @@
+// Copyright 2018 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lookup
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+const (
+	aliceObjectID = "4f2c1d3e-8a9b-4c5d-9e0f-1a2b3c4d5e6f"
+	sreObjectID   = "7a8b9c0d-1e2f-4a3b-8c4d-5e6f7a8b9c0d"
+)
+
+func TestGetAksClusterInfo(t *testing.T) {
+	config := clientcmdapi.Config{
+		Clusters: map[string]*clientcmdapi.Cluster{
+			"helloworld": {Server: "https://127.0.0.1:6443"},
+			"prod-aks":   {Server: "https://prod-dns-1a2b3c4d.hcp.eastus.azmk8s.io:443"},
+		},
+		Contexts: map[string]*clientcmdapi.Context{
+			"not-aks":    {Cluster: "helloworld"},
+			"actual-aks": {Cluster: "prod-aks"},
+		},
+		CurrentContext: "bar",
+	}
+
+	assert.Equal(t, "", getAksClusterInfo(&config, "").ClusterName)
+	assert.Equal(t, "", getAksClusterInfo(&config, "not-aks").ClusterName)
+	assert.Equal(t, "prod-aks", getAksClusterInfo(&config, "actual-aks").ClusterName)
+}
+
+func TestNewAADDirectory(t *testing.T) {
+	dir := t.TempDir()
+
+	users := filepath.Join(dir, "users.json")
+	err := os.WriteFile(users, []byte(`[{"id": "`+aliceObjectID+`", "displayName": "Alice", "userPrincipalName": "alice@contoso.com"}]`), 0600)
+	assert.Nil(t, err, "Expected no error writing users")
+
+	groups := filepath.Join(dir, "groups.csv")
+	err = os.WriteFile(groups, []byte("objectId,displayName\n"+sreObjectID+",SRE\n"), 0600)
+	assert.Nil(t, err, "Expected no error writing groups")
+
+	resolver, err := NewAADDirectory([]string{users, groups})
+	assert.Nil(t, err, "Expected no error reading directory")
+
+	identities, err := resolver.Resolve([]string{aliceObjectID, sreObjectID, "00000000-0000-0000-0000-000000000000"})
+	assert.Nil(t, err, "Expected no error resolving object IDs")
+	assert.Equal(t, map[string]AADIdentity{
+		aliceObjectID: {ObjectID: aliceObjectID, DisplayName: "Alice", UserPrincipalName: "alice@contoso.com"},
+		sreObjectID:   {ObjectID: sreObjectID, DisplayName: "SRE"},
+	}, identities)
+
+	_, err = parseAADDirectoryCSV(bytes.NewBufferString("displayName\nSRE\n"))
+	assert.NotNil(t, err, "Expected an error without an id column")
+}
+
+func TestLoadAks(t *testing.T) {
+	l := genLister()
+	l.matcher = &nameMatcher{query: "contoso.com"}
+	l.aadResolver = aadDirectory{
+		aliceObjectID: {ObjectID: aliceObjectID, DisplayName: "Alice", UserPrincipalName: "alice@contoso.com"},
+		sreObjectID:   {ObjectID: sreObjectID, DisplayName: "SRE"},
+	}
+
+	createRoleBindings(t, l)
+
+	roleBinding := rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "aad-edit",
+			Namespace: "web",
+		},
+		Subjects: []rbacv1.Subject{{
+			Name: aliceObjectID,
+			Kind: "User",
+		}, {
+			Name: sreObjectID,
+			Kind: "Group",
+		}},
+		RoleRef: rbacv1.RoleRef{
+			Kind: "ClusterRole",
+			Name: "edit",
+		},
+	}
+	_, err := l.clientset.RbacV1().RoleBindings("web").Create(context.Background(), &roleBinding, metav1.CreateOptions{})
+	assert.Nil(t, err, "Expected no error creating role bindings")
+
+	assert.Nil(t, l.loadAll(), "Expected no error loading all rbac Bindings")
+
+	assert.Equal(t, []string{aliceObjectID}, l.subjectNames(), "Expected to match alice by UPN")
+
+	var buf bytes.Buffer
+	err = l.printRbacBindings(&buf, "")
+	assert.Nil(t, err, "Expected no error printing")
+	assert.Equal(t, `SUBJECT              SCOPE   ROLE
+alice@contoso.com    web     ClusterRole/edit
+`, buf.String())
+
+	buf.Reset()
+	err = l.printRbacBindings(&buf, "wide")
+	assert.Nil(t, err, "Expected no error printing")
+	assert.Equal(t, `SUBJECT                                                          SCOPE   ROLE               SOURCE
+User/alice@contoso.com (4f2c1d3e-8a9b-4c5d-9e0f-1a2b3c4d5e6f)    web     ClusterRole/edit   RoleBinding/aad-edit
+`, buf.String())
+}
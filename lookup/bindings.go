@@ -0,0 +1,106 @@
+// Copyright 2018 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lookup
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// bindingView is a single binding along with the role it references and
+// every matching subject bound by it.
+type bindingView struct {
+	Source   simpleRoleSource
+	Scope    string
+	Role     simpleRole
+	Subjects []string
+}
+
+func (l *lister) bindingViews() []bindingView {
+	views := []bindingView{}
+	index := make(map[string]int)
+
+	// Rows are in subject order, so subjects are already sorted per binding
+	for _, row := range l.outputDocument().rows() {
+		key := fmt.Sprintf("%s/%s/%s/%s%s", row.Scope, row.Role.Source.Kind, row.Role.Source.Name, row.Role.Source.Level, row.Role.conditionSuffix())
+		subject := row.qualifiedSubject()
+		if row.Role.Source.Via != "" {
+			subject += fmt.Sprintf(" (via %s)", row.Role.Source.Via)
+		}
+
+		if i, ok := index[key]; ok {
+			views[i].Subjects = append(views[i].Subjects, subject)
+			continue
+		}
+
+		// The binding itself names the group, not the subject
+		source := row.Role.Source
+		source.Via = ""
+
+		index[key] = len(views)
+		views = append(views, bindingView{
+			Source:   source,
+			Scope:    row.Scope,
+			Role:     row.Role,
+			Subjects: []string{subject},
+		})
+	}
+
+	sort.SliceStable(views, func(i, j int) bool {
+		if views[i].Scope != views[j].Scope {
+			return scopeLess(views[i].Scope, views[j].Scope)
+		}
+		return sourceLess(views[i].Source, views[j].Source)
+	})
+
+	return views
+}
+
+// printBindingTable prints the binding-centric view, listing each binding
+// with its labels, the role it references and the subjects it binds.
+func (l *lister) printBindingTable(w io.Writer) error {
+	tw := new(tabwriter.Writer)
+	tw.Init(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "BINDING\t SCOPE\t ROLE\t SUBJECTS\t LABELS")
+
+	for _, view := range l.bindingViews() {
+		role := fmt.Sprintf("%s/%s%s", view.Role.Kind, view.Role.Name, view.Role.conditionSuffix())
+		if l.checkRefs && view.Role.Missing {
+			role += " (missing)"
+		}
+
+		fmt.Fprintf(tw, "%s \t %s\t %s\t %s\t %s\n", view.Source, view.Scope, role, strings.Join(view.Subjects, ","), formatLabels(view.Source.Labels))
+	}
+
+	return tw.Flush()
+}
+
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return "<none>"
+	}
+
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ",")
+}
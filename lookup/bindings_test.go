@@ -0,0 +1,87 @@
+// Copyright 2018 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lookup
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPrintBindingTable(t *testing.T) {
+	l := genLister()
+
+	createRoleBindings(t, l)
+	createClusterRoleBindings(t, l)
+
+	loadAll(t, l)
+
+	var buf bytes.Buffer
+	err := l.printRbacBindings(&buf, "bindings")
+	assert.Nil(t, err, "Expected no error printing")
+	assert.Equal(t, `BINDING                                      SCOPE          ROLE                        SUBJECTS                           LABELS
+ClusterRoleBinding/circleci-cluster-admin    cluster-wide   ClusterRole/cluster-admin   ServiceAccount/circleci:circleci   <none>
+ClusterRoleBinding/testing                   cluster-wide   ClusterRole/bar             User/joe,User/sue                  <none>
+RoleBinding/testing                          foo            Role/bar                    User/joe,User/sue                  <none>
+RoleBinding/testing-sa                       three          ClusterRole/cluster-admin   ServiceAccount/circleci:circleci   <none>
+RoleBinding/testing-sa                       two            ClusterRole/cluster-admin   ServiceAccount/circleci:circleci   <none>
+`, buf.String())
+}
+
+func TestLoadAllSelector(t *testing.T) {
+	l := genLister()
+	l.labelSelector = "team=payments"
+
+	createRoleBindings(t, l)
+	createClusterRoleBindings(t, l)
+
+	labeledBinding := rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "payments-edit",
+			Namespace: "payments",
+			Labels: map[string]string{
+				"team":                         "payments",
+				"app.kubernetes.io/managed-by": "Helm",
+			},
+		},
+		Subjects: []rbacv1.Subject{{
+			Name: "joe",
+			Kind: "User",
+		}},
+		RoleRef: rbacv1.RoleRef{
+			Kind: "ClusterRole",
+			Name: "edit",
+		},
+	}
+	_, err := l.clientset.RbacV1().RoleBindings("payments").Create(context.Background(), &labeledBinding, metav1.CreateOptions{})
+	assert.Nil(t, err, "Expected no error creating role bindings")
+
+	loadAll(t, l)
+
+	assert.Len(t, l.rbacSubjectsByScope, 1, "Expected 1 rbac subject")
+	assert.Len(t, l.rbacSubjectsByScope["joe"].RolesByScope, 1, "Expected only the labeled binding")
+
+	var buf bytes.Buffer
+	err = l.printRbacBindings(&buf, "bindings")
+	assert.Nil(t, err, "Expected no error printing")
+	assert.Equal(t, `BINDING                      SCOPE      ROLE               SUBJECTS   LABELS
+RoleBinding/payments-edit    payments   ClusterRole/edit   User/joe   app.kubernetes.io/managed-by=Helm,team=payments
+`, buf.String())
+}
@@ -0,0 +1,201 @@
+// Copyright 2018 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lookup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// EKS maps IAM roles and users to Kubernetes users and groups with the
+// aws-auth ConfigMap.
+const (
+	awsAuthNamespace = "kube-system"
+	awsAuthName      = "aws-auth"
+)
+
+// Subject kinds for IAM identities mapped by aws-auth.
+const (
+	iamRoleKind = "IAMRole"
+	iamUserKind = "IAMUser"
+)
+
+// awsAuth holds the IAM identity mappings from the aws-auth ConfigMap.
+type awsAuth struct {
+	Mappings []eksIdentityMapping
+	Accounts []string
+}
+
+// eksIdentityMapping maps an IAM role or user ARN to the Kubernetes user
+// and groups it authenticates as.
+type eksIdentityMapping struct {
+	ARN      string
+	Kind     string
+	Username string
+	Groups   []string
+}
+
+// awsAuthEntry is a single mapRoles or mapUsers entry.
+type awsAuthEntry struct {
+	RoleARN  string   `json:"rolearn"`
+	UserARN  string   `json:"userarn"`
+	Username string   `json:"username"`
+	Groups   []string `json:"groups"`
+}
+
+func (l *lister) loadAwsAuth() error {
+	if !l.eks || l.awsAuth != nil {
+		return nil
+	}
+
+	configMap, err := l.clientset.CoreV1().ConfigMaps(awsAuthNamespace).Get(context.Background(), awsAuthName, metav1.GetOptions{})
+
+	// Clusters using access entries instead don't have an aws-auth ConfigMap
+	if apierrors.IsNotFound(err) {
+		fmt.Fprintf(os.Stderr, "No %s/%s ConfigMap found, no IAM identities are mapped\n", awsAuthNamespace, awsAuthName)
+		l.awsAuth = &awsAuth{}
+		return nil
+	}
+	if err != nil {
+		fmt.Println("Error loading aws-auth ConfigMap")
+		return err
+	}
+
+	auth, err := parseAwsAuth(configMap.Data)
+	if err != nil {
+		return fmt.Errorf("invalid %s/%s ConfigMap: %v", awsAuthNamespace, awsAuthName, err)
+	}
+
+	l.awsAuth = auth
+	return nil
+}
+
+func parseAwsAuth(data map[string]string) (*awsAuth, error) {
+	auth := awsAuth{}
+
+	var roles, users []awsAuthEntry
+	if err := yaml.Unmarshal([]byte(data["mapRoles"]), &roles); err != nil {
+		return nil, fmt.Errorf("mapRoles: %v", err)
+	}
+	if err := yaml.Unmarshal([]byte(data["mapUsers"]), &users); err != nil {
+		return nil, fmt.Errorf("mapUsers: %v", err)
+	}
+
+	for _, role := range roles {
+		auth.Mappings = append(auth.Mappings, eksIdentityMapping{
+			ARN:      role.RoleARN,
+			Kind:     iamRoleKind,
+			Username: role.Username,
+			Groups:   role.Groups,
+		})
+	}
+	for _, user := range users {
+		auth.Mappings = append(auth.Mappings, eksIdentityMapping{
+			ARN:      user.UserARN,
+			Kind:     iamUserKind,
+			Username: user.Username,
+			Groups:   user.Groups,
+		})
+	}
+
+	// Account IDs are often left unquoted, so keep numbers as written
+	var accounts []interface{}
+	useNumber := func(d *json.Decoder) *json.Decoder {
+		d.UseNumber()
+		return d
+	}
+	if err := yaml.Unmarshal([]byte(data["mapAccounts"]), &accounts, useNumber); err != nil {
+		return nil, fmt.Errorf("mapAccounts: %v", err)
+	}
+	for _, account := range accounts {
+		auth.Accounts = append(auth.Accounts, fmt.Sprint(account))
+	}
+
+	return &auth, nil
+}
+
+// accountMapped reports whether arn is an IAM role or user from one of the
+// mapped accounts without a mapping of its own. Those authenticate with
+// their ARN as their username and no groups.
+func (a *awsAuth) accountMapped(arn string) (string, string, bool) {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" || !contains(a.Accounts, parts[4]) {
+		return "", "", false
+	}
+
+	for _, mapping := range a.Mappings {
+		if mapping.ARN == arn {
+			return "", "", false
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(parts[5], "role/"), strings.HasPrefix(parts[5], "assumed-role/"):
+		return arn, iamRoleKind, true
+	case strings.HasPrefix(parts[5], "user/"):
+		return arn, iamUserKind, true
+	default:
+		return "", "", false
+	}
+}
+
+// identitiesFor returns the Kubernetes user and groups an IAM identity
+// authenticates as. Usernames with templates such as {{SessionName}} are
+// only known once someone authenticates, so only groups are returned.
+func (a *awsAuth) identitiesFor(arn, kind string, includeImplicitGroups bool) []identity {
+	if kind != iamRoleKind && kind != iamUserKind {
+		return nil
+	}
+
+	var username string
+	var groups []string
+
+	found := false
+	for _, mapping := range a.Mappings {
+		if mapping.ARN == arn {
+			username, groups = mapping.Username, mapping.Groups
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		if _, _, ok := a.accountMapped(arn); !ok {
+			return nil
+		}
+		username = arn
+	}
+
+	identities := []identity{}
+	if username != "" && !strings.Contains(username, "{{") {
+		identities = append(identities, identity{Kind: rbacv1.UserKind, Name: username})
+	}
+	for _, group := range groups {
+		identities = append(identities, identity{Kind: rbacv1.GroupKind, Name: group})
+	}
+	if includeImplicitGroups {
+		identities = append(identities, identity{Kind: rbacv1.GroupKind, Name: authenticatedGroup})
+	}
+
+	return identities
+}
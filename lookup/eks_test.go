@@ -0,0 +1,207 @@
+// Copyright 2018 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lookup
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var awsAuthData = map[string]string{
+	"mapRoles": `- rolearn: arn:aws:iam::111122223333:role/eks-nodes
+  username: system:node:{{EC2PrivateDNSName}}
+  groups:
+  - system:bootstrappers
+  - system:nodes
+- rolearn: arn:aws:iam::111122223333:role/admin
+  username: admin
+  groups:
+  - system:masters
+`,
+	"mapUsers": `- userarn: arn:aws:iam::111122223333:user/alice
+  username: alice
+  groups: [developers]
+`,
+	"mapAccounts": `- 444455556666
+- "777788889999"
+`,
+}
+
+func TestParseAwsAuth(t *testing.T) {
+	auth, err := parseAwsAuth(awsAuthData)
+	assert.Nil(t, err, "Expected no error parsing aws-auth")
+
+	assert.Equal(t, []eksIdentityMapping{{
+		ARN:      "arn:aws:iam::111122223333:role/eks-nodes",
+		Kind:     "IAMRole",
+		Username: "system:node:{{EC2PrivateDNSName}}",
+		Groups:   []string{"system:bootstrappers", "system:nodes"},
+	}, {
+		ARN:      "arn:aws:iam::111122223333:role/admin",
+		Kind:     "IAMRole",
+		Username: "admin",
+		Groups:   []string{"system:masters"},
+	}, {
+		ARN:      "arn:aws:iam::111122223333:user/alice",
+		Kind:     "IAMUser",
+		Username: "alice",
+		Groups:   []string{"developers"},
+	}}, auth.Mappings)
+	assert.Equal(t, []string{"444455556666", "777788889999"}, auth.Accounts)
+
+	assert.Equal(t, []identity{
+		{Kind: "Group", Name: "system:bootstrappers"},
+		{Kind: "Group", Name: "system:nodes"},
+	}, auth.identitiesFor("arn:aws:iam::111122223333:role/eks-nodes", "IAMRole", false), "Expected templated usernames to be left out")
+
+	arn, kind, ok := auth.accountMapped("arn:aws:iam::444455556666:role/ci")
+	assert.True(t, ok, "Expected roles in mapped accounts to be mapped")
+	assert.Equal(t, "arn:aws:iam::444455556666:role/ci", arn)
+	assert.Equal(t, "IAMRole", kind)
+
+	_, _, ok = auth.accountMapped("arn:aws:iam::111122223333:role/ci")
+	assert.False(t, ok, "Expected roles in other accounts not to be mapped")
+
+	_, err = parseAwsAuth(map[string]string{"mapRoles": "rolearn: not-a-list"})
+	assert.NotNil(t, err, "Expected an error for invalid mapRoles")
+}
+
+func TestLoadEks(t *testing.T) {
+	l := genLister()
+	l.eks = true
+	l.matcher = &nameMatcher{query: "arn:aws:iam::111122223333:"}
+
+	configMap := corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      awsAuthName,
+			Namespace: awsAuthNamespace,
+		},
+		Data: awsAuthData,
+	}
+	_, err := l.clientset.CoreV1().ConfigMaps(awsAuthNamespace).Create(context.Background(), &configMap, metav1.CreateOptions{})
+	assert.Nil(t, err, "Expected no error creating aws-auth")
+
+	clusterRoleBindings := []rbacv1.ClusterRoleBinding{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "masters",
+		},
+		Subjects: []rbacv1.Subject{{
+			Name: "system:masters",
+			Kind: "Group",
+		}},
+		RoleRef: rbacv1.RoleRef{
+			Kind: "ClusterRole",
+			Name: "cluster-admin",
+		},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "system:discovery",
+		},
+		Subjects: []rbacv1.Subject{{
+			Name: "system:authenticated",
+			Kind: "Group",
+		}},
+		RoleRef: rbacv1.RoleRef{
+			Kind: "ClusterRole",
+			Name: "system:discovery",
+		},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "alice-view",
+		},
+		Subjects: []rbacv1.Subject{{
+			Name: "alice",
+			Kind: "User",
+		}},
+		RoleRef: rbacv1.RoleRef{
+			Kind: "ClusterRole",
+			Name: "view",
+		},
+	}}
+
+	for _, clusterRoleBinding := range clusterRoleBindings {
+		_, err := l.clientset.RbacV1().ClusterRoleBindings().Create(context.Background(), &clusterRoleBinding, metav1.CreateOptions{})
+		assert.Nil(t, err, "Expected no error creating cluster role bindings")
+	}
+
+	developers := rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "developers",
+			Namespace: "web",
+		},
+		Subjects: []rbacv1.Subject{{
+			Name: "developers",
+			Kind: "Group",
+		}},
+		RoleRef: rbacv1.RoleRef{
+			Kind: "ClusterRole",
+			Name: "edit",
+		},
+	}
+	_, err = l.clientset.RbacV1().RoleBindings("web").Create(context.Background(), &developers, metav1.CreateOptions{})
+	assert.Nil(t, err, "Expected no error creating role bindings")
+
+	assert.Nil(t, l.loadAll(), "Expected no error loading all rbac Bindings")
+
+	var buf bytes.Buffer
+	err = l.printRbacBindings(&buf, "wide")
+	assert.Nil(t, err, "Expected no error printing")
+	assert.Equal(t, `SUBJECT                                         SCOPE          ROLE                        SOURCE
+IAMRole/arn:aws:iam::111122223333:role/admin    cluster-wide   ClusterRole/cluster-admin   ClusterRoleBinding/masters (via system:masters)
+IAMUser/arn:aws:iam::111122223333:user/alice    cluster-wide   ClusterRole/view            ClusterRoleBinding/alice-view (via alice)
+IAMUser/arn:aws:iam::111122223333:user/alice    web            ClusterRole/edit            RoleBinding/developers (via developers)
+`, buf.String())
+
+	// Roles in mapped accounts are only granted what every authenticated
+	// user is, since they have no groups
+	arn := "arn:aws:iam::444455556666:role/ci"
+	ql := l.forQuery(arn, &nameMatcher{rawQuery: arn, query: arn, mode: "exact"})
+	ql.includeImplicitGroups = true
+	assert.Nil(t, ql.loadAll(), "Expected no error loading all rbac Bindings")
+
+	assert.Equal(t, rbacSubject{
+		Kind: "IAMRole",
+		RolesByScope: map[string][]simpleRole{
+			"cluster-wide": {{
+				Kind: "ClusterRole",
+				Name: "system:discovery",
+				Source: simpleRoleSource{
+					Kind: "ClusterRoleBinding",
+					Name: "system:discovery",
+					Via:  "system:authenticated",
+				},
+			}},
+		},
+	}, ql.rbacSubjectsByScope[arn])
+}
+
+func TestLoadEksWithoutAwsAuth(t *testing.T) {
+	l := genLister()
+	l.eks = true
+	l.matcher = &nameMatcher{query: "joe"}
+
+	createRoleBindings(t, l)
+
+	assert.Nil(t, l.loadAll(), "Expected a missing aws-auth ConfigMap not to be an error")
+	assert.Empty(t, l.awsAuth.Mappings)
+	assert.Contains(t, l.rbacSubjectsByScope, "joe")
+}
@@ -0,0 +1,63 @@
+// Copyright 2018 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lookup
+
+import (
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// systemPrefix is reserved for subjects managed by Kubernetes itself, such
+// as system:masters or system:kube-scheduler.
+const systemPrefix = "system:"
+
+// autoupdateAnnotation marks the default bindings the API server creates
+// and reconciles on startup.
+const autoupdateAnnotation = "rbac.authorization.kubernetes.io/autoupdate"
+
+func newExcludeMatchers(patterns []string, ignoreCase bool) ([]*nameMatcher, error) {
+	matchers := make([]*nameMatcher, 0, len(patterns))
+	for _, pattern := range patterns {
+		m, err := newNameMatcher(pattern, "glob", ignoreCase)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, m)
+	}
+	return matchers, nil
+}
+
+// subjectExcluded reports whether a subject should be left out, either
+// because it is a system subject or it matches an --exclude pattern.
+func (l *lister) subjectExcluded(name string) bool {
+	if l.excludeSystem && strings.HasPrefix(name, systemPrefix) {
+		return true
+	}
+
+	for _, m := range l.exclude {
+		if m.query != "" && m.matches(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// bindingExcluded reports whether a binding is one of the bootstrap
+// defaults left out by --exclude-system.
+func (l *lister) bindingExcluded(meta metav1.ObjectMeta) bool {
+	return l.excludeSystem && meta.Annotations[autoupdateAnnotation] == "true"
+}
@@ -0,0 +1,92 @@
+// Copyright 2018 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lookup
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestExcludeSystem(t *testing.T) {
+	l := genLister()
+	l.excludeSystem = true
+
+	createRoleBindings(t, l)
+	createClusterRoleBindings(t, l)
+
+	clusterRoleBindings := []rbacv1.ClusterRoleBinding{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "cluster-admin",
+			Annotations: map[string]string{
+				autoupdateAnnotation: "true",
+			},
+		},
+		Subjects: []rbacv1.Subject{{
+			Name: "bootstrap-admin",
+			Kind: "User",
+		}},
+		RoleRef: rbacv1.RoleRef{
+			Kind: "ClusterRole",
+			Name: "cluster-admin",
+		},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "masters",
+		},
+		Subjects: []rbacv1.Subject{{
+			Name: "system:masters",
+			Kind: "Group",
+		}, {
+			Name: "admins",
+			Kind: "Group",
+		}},
+		RoleRef: rbacv1.RoleRef{
+			Kind: "ClusterRole",
+			Name: "cluster-admin",
+		},
+	}}
+
+	for _, clusterRoleBinding := range clusterRoleBindings {
+		_, err := l.clientset.RbacV1().ClusterRoleBindings().Create(context.Background(), &clusterRoleBinding, metav1.CreateOptions{})
+		assert.Nil(t, err, "Expected no error creating cluster role bindings")
+	}
+
+	loadAll(t, l)
+
+	assert.Equal(t, []string{"admins", "circleci:circleci", "joe", "sue"}, l.subjectNames())
+}
+
+func TestExcludePatterns(t *testing.T) {
+	l := genLister()
+
+	exclude, err := newExcludeMatchers([]string{"circleci:*", "SU?"}, true)
+	assert.Nil(t, err, "Expected no error creating exclude matchers")
+	l.exclude = exclude
+
+	createRoleBindings(t, l)
+	createClusterRoleBindings(t, l)
+
+	loadAll(t, l)
+
+	assert.Equal(t, []string{"joe"}, l.subjectNames())
+
+	_, err = newExcludeMatchers([]string{"admin["}, false)
+	assert.NotNil(t, err, "Expected an error for an invalid glob")
+}
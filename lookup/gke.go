@@ -23,6 +23,8 @@ import (
 	"golang.org/x/net/context"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/cloudresourcemanager/v1"
+	crmv2 "google.golang.org/api/cloudresourcemanager/v2"
+	"google.golang.org/api/iam/v1"
 
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 
@@ -56,68 +58,292 @@ func getClusterInfo(c *clientcmdapi.Config, kubeContext string) *gkeClusterInfo
 	return &ci
 }
 
-func loadGkeIAMPolicy(parsedProjectName string) (*cloudresourcemanager.Policy, error) {
+// gkePolicyVersion is the IAM policy version requested, the first to include
+// conditional bindings.
+const gkePolicyVersion = 3
+
+// iamCondition is the condition a conditional IAM binding grants its role
+// under, such as an expiry time.
+type iamCondition struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Expression  string `json:"expression"`
+}
+
+// String describes the condition by its title, or its expression if it has
+// no title.
+func (c iamCondition) String() string {
+	if c.Title != "" {
+		return c.Title
+	}
+	return c.Expression
+}
+
+// iamMember is a principal named in an IAM binding.
+type iamMember struct {
+	Kind string
+	Name string
+	// Deleted is set for principals deleted since they were granted the role
+	Deleted bool
+}
+
+// parseIamMember parses an IAM binding member such as user:jane@example.com,
+// deleted:user:jane@example.com?uid=123, allUsers or a principal:// URI.
+// Principals that aren't a single account or group are named by the whole
+// member, and deleted ones keep their uid so they aren't confused with an
+// account of the same name created since.
+func parseIamMember(member string) (iamMember, bool) {
+	switch {
+	case member == "allUsers" || member == "allAuthenticatedUsers":
+		return iamMember{Kind: "Group", Name: member}, true
+	case strings.HasPrefix(member, "principal://"):
+		return iamMember{Kind: "Principal", Name: member}, true
+	case strings.HasPrefix(member, "principalSet://"):
+		return iamMember{Kind: "PrincipalSet", Name: member}, true
+	}
+
+	prefix, name, ok := strings.Cut(member, ":")
+	if !ok || name == "" {
+		return iamMember{}, false
+	}
+
+	if prefix == "deleted" {
+		deleted, ok := parseIamMember(name)
+		deleted.Deleted = true
+		return deleted, ok
+	}
+
+	return iamMember{Kind: strings.Title(prefix), Name: name}, true
+}
+
+// gkeIamPolicy is the IAM policy of the cluster's project or one of the
+// folders or organization it inherits bindings from.
+type gkeIamPolicy struct {
+	// Level is the resource the policy is set on, e.g. folders/1234
+	Level    string
+	Bindings []*cloudresourcemanager.Binding
+}
+
+// loadGkeIAMPolicies loads the IAM policy of the project along with those of
+// every folder and organization above it, nearest first, and resolves the
+// roles they grant. It also returns the read-only IAM service it used, for
+// looking up Google service account policies.
+func loadGkeIAMPolicies(parsedProjectName string) ([]gkeIamPolicy, map[string]simpleRole, *iam.Service, error) {
 	ctx := context.Background()
 
 	c, err := google.DefaultClient(ctx, cloudresourcemanager.CloudPlatformReadOnlyScope)
 	if err != nil {
 		fmt.Println("Error initializing Google API client")
-		return nil, err
+		return nil, nil, nil, err
 	}
 
 	crmService, err := cloudresourcemanager.New(c)
 	if err != nil {
 		fmt.Println("Error initializing Google Cloud Resource Manager")
-		return nil, err
+		return nil, nil, nil, err
+	}
+
+	foldersService, err := crmv2.New(c)
+	if err != nil {
+		fmt.Println("Error initializing Google Cloud Resource Manager")
+		return nil, nil, nil, err
 	}
 
-	ipr := &cloudresourcemanager.GetIamPolicyRequest{}
+	projectID, policy, err := loadGkeIAMPolicy(crmService, parsedProjectName)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	iamService, err := iam.New(c)
+	if err != nil {
+		fmt.Println("Error initializing Google IAM API client")
+		return nil, nil, nil, err
+	}
+
+	policies := loadGkeAncestorPolicies(ctx, crmService, foldersService, projectID, policy)
+	return policies, resolveGkeIamRoles(ctx, iamService, policies), iamService, nil
+}
+
+// loadGkeAncestorPolicies adds the policies of the folders and organization
+// above a project to its own. Ancestors whose policy can't be read are
+// skipped, as viewing them needs permissions outside the project.
+func loadGkeAncestorPolicies(ctx context.Context, crmService *cloudresourcemanager.Service, foldersService *crmv2.Service, projectID string, policy *cloudresourcemanager.Policy) []gkeIamPolicy {
+	policies := []gkeIamPolicy{{
+		Level:    "projects/" + projectID,
+		Bindings: policy.Bindings,
+	}}
+
+	ancestry, err := crmService.Projects.GetAncestry(projectID, &cloudresourcemanager.GetAncestryRequest{}).Context(ctx).Do()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not load resource hierarchy for %s project, only including its own IAM policy\n", projectID)
+		return policies
+	}
+
+	for _, ancestor := range ancestry.Ancestor {
+		if ancestor.ResourceId == nil {
+			continue
+		}
+
+		switch ancestor.ResourceId.Type {
+		case "folder":
+			level := "folders/" + ancestor.ResourceId.Id
+			folderPolicy, err := foldersService.Folders.GetIamPolicy(level, &crmv2.GetIamPolicyRequest{
+				Options: &crmv2.GetPolicyOptions{RequestedPolicyVersion: gkePolicyVersion},
+			}).Context(ctx).Do()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Could not load IAM policy for %s\n", level)
+				continue
+			}
+
+			bindings := make([]*cloudresourcemanager.Binding, 0, len(folderPolicy.Bindings))
+			for _, binding := range folderPolicy.Bindings {
+				b := &cloudresourcemanager.Binding{
+					Role:    binding.Role,
+					Members: binding.Members,
+				}
+				if binding.Condition != nil {
+					b.Condition = &cloudresourcemanager.Expr{
+						Title:       binding.Condition.Title,
+						Description: binding.Condition.Description,
+						Expression:  binding.Condition.Expression,
+					}
+				}
+				bindings = append(bindings, b)
+			}
+			policies = append(policies, gkeIamPolicy{Level: level, Bindings: bindings})
+		case "organization":
+			level := "organizations/" + ancestor.ResourceId.Id
+			orgPolicy, err := crmService.Organizations.GetIamPolicy(level, gkePolicyRequest()).Context(ctx).Do()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Could not load IAM policy for %s\n", level)
+				continue
+			}
+			policies = append(policies, gkeIamPolicy{Level: level, Bindings: orgPolicy.Bindings})
+		}
+	}
+
+	return policies
+}
+
+// resolveGkeIamRoles looks up the permissions of every custom and
+// predefined role granted by the policies. Roles in gkeIamRoles keep their
+// names and are given the rules their permissions are equivalent to, while
+// other roles are only kept if they have container permissions. Roles that
+// can't be read are skipped.
+func resolveGkeIamRoles(ctx context.Context, iamService *iam.Service, policies []gkeIamPolicy) map[string]simpleRole {
+	roles := make(map[string]simpleRole)
+	seen := make(map[string]bool)
+
+	for _, policy := range policies {
+		for _, binding := range policy.Bindings {
+			if seen[binding.Role] {
+				continue
+			}
+			seen[binding.Role] = true
+
+			var role *iam.Role
+			var err error
+			switch {
+			case strings.HasPrefix(binding.Role, "projects/"):
+				role, err = iamService.Projects.Roles.Get(binding.Role).Context(ctx).Do()
+			case strings.HasPrefix(binding.Role, "organizations/"):
+				role, err = iamService.Organizations.Roles.Get(binding.Role).Context(ctx).Do()
+			default:
+				role, err = iamService.Roles.Get(binding.Role).Context(ctx).Do()
+			}
+
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Could not load IAM role %s\n", binding.Role)
+				continue
+			}
+
+			if sr, ok := gkeIamRoles[binding.Role]; ok {
+				sr.Rules = containerPermissionRules(role.IncludedPermissions)
+				roles[binding.Role] = sr
+			} else if sr, ok := gkeIamRole(binding.Role, role.IncludedPermissions); ok {
+				roles[binding.Role] = sr
+			}
+		}
+	}
+
+	return roles
+}
+
+// loadServiceAccountPolicies loads the IAM policies of Google service
+// accounts by email, skipping any that can't be read.
+func loadServiceAccountPolicies(ctx context.Context, iamService *iam.Service, emails []string) map[string]*iam.Policy {
+	policies := make(map[string]*iam.Policy, len(emails))
+
+	for _, email := range emails {
+		policy, err := iamService.Projects.ServiceAccounts.GetIamPolicy("projects/-/serviceAccounts/" + email).OptionsRequestedPolicyVersion(gkePolicyVersion).Context(ctx).Do()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not load IAM policy for %s service account\n", email)
+			continue
+		}
+		policies[email] = policy
+	}
+
+	return policies
+}
+
+func gkePolicyRequest() *cloudresourcemanager.GetIamPolicyRequest {
+	return &cloudresourcemanager.GetIamPolicyRequest{
+		Options: &cloudresourcemanager.GetPolicyOptions{RequestedPolicyVersion: gkePolicyVersion},
+	}
+}
+
+// loadGkeIAMPolicy loads the IAM policy of the project parsed from the
+// kubeconfig, falling back to the default credentials' project and then
+// CLOUDSDK_CORE_PROJECT. It returns the ID of the project it loaded.
+func loadGkeIAMPolicy(crmService *cloudresourcemanager.Service, parsedProjectName string) (string, *cloudresourcemanager.Policy, error) {
+	ctx := context.Background()
+	ipr := gkePolicyRequest()
 
 	var policy *cloudresourcemanager.Policy
 	var err1, err2, err3 error
 
 	policy, err1 = crmService.Projects.GetIamPolicy(parsedProjectName, ipr).Context(ctx).Do()
 	if err1 != nil {
-		fmt.Printf("Could not load IAM policy for %s project from parsed kubeconfig\n", parsedProjectName)
+		fmt.Fprintf(os.Stderr, "Could not load IAM policy for %s project from parsed kubeconfig\n", parsedProjectName)
 
 		var credentials *google.Credentials
 		credentials, err2 = google.FindDefaultCredentials(ctx, cloudresourcemanager.CloudPlatformReadOnlyScope)
 
 		if err2 != nil {
-			return nil, err2
+			return "", nil, err2
 		}
 
 		if credentials.ProjectID == "" {
-			fmt.Println("No project ID found in default GCP credentials")
+			fmt.Fprintln(os.Stderr, "No project ID found in default GCP credentials")
 			return getPolicyFromEnvVar(crmService, ipr)
 		}
 
 		policy, err3 = crmService.Projects.GetIamPolicy(credentials.ProjectID, ipr).Context(ctx).Do()
 
 		if err3 != nil {
-			fmt.Printf("Could not load IAM policy for %s project from default GCP credentials\n", credentials.ProjectID)
+			fmt.Fprintf(os.Stderr, "Could not load IAM policy for %s project from default GCP credentials\n", credentials.ProjectID)
 			return getPolicyFromEnvVar(crmService, ipr)
 		}
 
-		return policy, nil
+		return credentials.ProjectID, policy, nil
 	}
 
-	return policy, nil
+	return parsedProjectName, policy, nil
 }
 
-func getPolicyFromEnvVar(crmService *cloudresourcemanager.Service, ipr *cloudresourcemanager.GetIamPolicyRequest) (*cloudresourcemanager.Policy, error) {
+func getPolicyFromEnvVar(crmService *cloudresourcemanager.Service, ipr *cloudresourcemanager.GetIamPolicyRequest) (string, *cloudresourcemanager.Policy, error) {
 	envVar := os.Getenv("CLOUDSDK_CORE_PROJECT")
 	if envVar == "" {
-		return nil, errors.New("Error loading IAM policies for GKE, try setting CLOUDSDK_CORE_PROJECT environment variable")
+		return "", nil, errors.New("Error loading IAM policies for GKE, try setting CLOUDSDK_CORE_PROJECT environment variable")
 	}
 
 	policy, err := crmService.Projects.GetIamPolicy(envVar, ipr).Context(context.Background()).Do()
 
 	if err != nil {
-		fmt.Printf("Could not load IAM policy for %s project from CLOUDSDK_CORE_PROJECT environment variable\n", envVar)
-		return nil, err
+		fmt.Fprintf(os.Stderr, "Could not load IAM policy for %s project from CLOUDSDK_CORE_PROJECT environment variable\n", envVar)
+		return "", nil, err
 	}
 
-	fmt.Printf("GCP IAM policy loaded for %s project from CLOUDSDK_CORE_PROJECT environment variable\n\n", envVar)
-	return policy, nil
+	fmt.Fprintf(os.Stderr, "GCP IAM policy loaded for %s project from CLOUDSDK_CORE_PROJECT environment variable\n\n", envVar)
+	return envVar, policy, nil
 }
@@ -14,6 +14,13 @@
 
 package lookup
 
+import (
+	"sort"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
 var gkeIamScope = "project-wide"
 var gkeIamRoles = map[string]simpleRole{
 	"roles/container.clusterAdmin": {
@@ -81,3 +88,184 @@ var gkeIamRoles = map[string]simpleRole{
 		},
 	},
 }
+
+// gkeApiResources are container permission resources for the GKE API rather
+// than the Kubernetes API, so they don't grant access within the cluster.
+var gkeApiResources = map[string]bool{
+	"clusters":         true,
+	"operations":       true,
+	"hostServiceAgent": true,
+}
+
+// gkeApiGroups maps the resources in container permissions to their
+// Kubernetes API group. Resources not listed are matched in any group.
+var gkeApiGroups = map[string]string{
+	"bindings":                        "",
+	"componentStatuses":               "",
+	"configMaps":                      "",
+	"endpoints":                       "",
+	"events":                          "",
+	"limitRanges":                     "",
+	"namespaces":                      "",
+	"nodes":                           "",
+	"persistentVolumeClaims":          "",
+	"persistentVolumes":               "",
+	"podTemplates":                    "",
+	"pods":                            "",
+	"replicationControllers":          "",
+	"resourceQuotas":                  "",
+	"secrets":                         "",
+	"serviceAccounts":                 "",
+	"services":                        "",
+	"mutatingWebhookConfigurations":   "admissionregistration.k8s.io",
+	"validatingWebhookConfigurations": "admissionregistration.k8s.io",
+	"customResourceDefinitions":       "apiextensions.k8s.io",
+	"apiServices":                     "apiregistration.k8s.io",
+	"controllerRevisions":             "apps",
+	"daemonSets":                      "apps",
+	"deployments":                     "apps",
+	"replicaSets":                     "apps",
+	"statefulSets":                    "apps",
+	"horizontalPodAutoscalers":        "autoscaling",
+	"cronJobs":                        "batch",
+	"jobs":                            "batch",
+	"certificateSigningRequests":      "certificates.k8s.io",
+	"ingresses":                       "networking.k8s.io",
+	"networkPolicies":                 "networking.k8s.io",
+	"podDisruptionBudgets":            "policy",
+	"podSecurityPolicies":             "policy",
+	"clusterRoleBindings":             "rbac.authorization.k8s.io",
+	"clusterRoles":                    "rbac.authorization.k8s.io",
+	"roleBindings":                    "rbac.authorization.k8s.io",
+	"roles":                           "rbac.authorization.k8s.io",
+	"storageClasses":                  "storage.k8s.io",
+}
+
+// gkeCustomResources is the container permission resource that covers every
+// custom resource rather than a single Kubernetes resource.
+const gkeCustomResources = "thirdPartyObjects"
+
+// gkeVerbs maps container permission verbs that act on a resource itself to
+// the Kubernetes verbs they allow.
+var gkeVerbs = map[string][]string{
+	"bind":        {"bind"},
+	"create":      {"create"},
+	"delete":      {"delete"},
+	"escalate":    {"escalate"},
+	"get":         {"get"},
+	"impersonate": {"impersonate"},
+	"list":        {"list"},
+	"update":      {"update", "patch"},
+	"use":         {"use"},
+	"watch":       {"watch"},
+}
+
+// gkeSubresourceVerbs maps container permission verbs that act on a
+// subresource to the subresource and the Kubernetes verbs they allow.
+var gkeSubresourceVerbs = map[string]struct {
+	subresource string
+	verbs       []string
+}{
+	"approve":      {"approval", []string{"update", "patch"}},
+	"attach":       {"attach", []string{"create"}},
+	"createToken":  {"token", []string{"create"}},
+	"evict":        {"eviction", []string{"create"}},
+	"exec":         {"exec", []string{"create"}},
+	"getLogs":      {"log", []string{"get"}},
+	"getScale":     {"scale", []string{"get"}},
+	"getStatus":    {"status", []string{"get"}},
+	"portForward":  {"portforward", []string{"create"}},
+	"proxy":        {"proxy", []string{"get", "create", "update", "patch", "delete"}},
+	"updateScale":  {"scale", []string{"update", "patch"}},
+	"updateStatus": {"status", []string{"update", "patch"}},
+}
+
+// gkeIamRole returns the role reported for a custom or predefined IAM role
+// that isn't in gkeIamRoles, using its real name. Roles without any container
+// permissions don't grant cluster access and are reported as not ok.
+func gkeIamRole(name string, permissions []string) (simpleRole, bool) {
+	hasContainerPermissions := false
+	for _, permission := range permissions {
+		if strings.HasPrefix(permission, "container.") {
+			hasContainerPermissions = true
+			break
+		}
+	}
+
+	if !hasContainerPermissions {
+		return simpleRole{}, false
+	}
+
+	roleName := strings.TrimPrefix(name, "roles/")
+	return simpleRole{
+		Kind: "IAM",
+		Name: roleName,
+		Source: simpleRoleSource{
+			Kind: "IAMRole",
+			Name: roleName,
+		},
+		Rules: containerPermissionRules(permissions),
+	}, true
+}
+
+// containerPermissionRules converts container permissions such as
+// container.pods.exec into the equivalent Kubernetes rules, with one rule per
+// resource. Permissions for the GKE API itself and verbs without a Kubernetes
+// equivalent are left out.
+func containerPermissionRules(permissions []string) []roleRule {
+	verbsByResource := make(map[string][]string)
+	groupByResource := make(map[string]string)
+
+	for _, permission := range permissions {
+		parts := strings.Split(permission, ".")
+		if len(parts) != 3 || parts[0] != "container" || gkeApiResources[parts[1]] {
+			continue
+		}
+
+		resource, verb := parts[1], parts[2]
+		apiGroup, known := gkeApiGroups[resource]
+		if !known {
+			apiGroup = "*"
+		}
+
+		resource = strings.ToLower(resource)
+		if parts[1] == gkeCustomResources {
+			resource = rbacv1.ResourceAll
+		}
+
+		verbs, ok := gkeVerbs[verb]
+		if sv, isSubresource := gkeSubresourceVerbs[verb]; isSubresource {
+			resource = resource + "/" + sv.subresource
+			verbs = sv.verbs
+		} else if !ok {
+			continue
+		}
+
+		for _, v := range verbs {
+			if !contains(verbsByResource[resource], v) {
+				verbsByResource[resource] = append(verbsByResource[resource], v)
+			}
+		}
+		groupByResource[resource] = apiGroup
+	}
+
+	resources := make([]string, 0, len(verbsByResource))
+	for resource := range verbsByResource {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+
+	rules := make([]roleRule, 0, len(resources))
+	for _, resource := range resources {
+		rules = append(rules, roleRule{
+			PolicyRule: rbacv1.PolicyRule{
+				Verbs:     verbsByResource[resource],
+				APIGroups: []string{groupByResource[resource]},
+				Resources: []string{resource},
+			},
+			CustomResources: strings.HasPrefix(resource, rbacv1.ResourceAll),
+		})
+	}
+
+	return rules
+}
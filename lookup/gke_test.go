@@ -15,9 +15,21 @@
 package lookup
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/api/cloudresourcemanager/v1"
+	crmv2 "google.golang.org/api/cloudresourcemanager/v2"
+	"google.golang.org/api/iam/v1"
+	"google.golang.org/api/option"
+	rbacv1 "k8s.io/api/rbac/v1"
 
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 )
@@ -39,3 +51,319 @@ func TestGetClusterInfo(t *testing.T) {
 	assert.Equal(t, getClusterInfo(&config1, "not-gke").ClusterName, "")
 	assert.Equal(t, getClusterInfo(&config1, "actual-gke").ClusterName, "rbac-lookup-testing")
 }
+
+func TestLoadGkeAncestorPolicies(t *testing.T) {
+	responses := map[string]string{
+		"/v1/projects/example:getAncestry": `{"ancestor": [
+			{"resourceId": {"type": "project", "id": "example"}},
+			{"resourceId": {"type": "folder", "id": "1234"}},
+			{"resourceId": {"type": "folder", "id": "5678"}},
+			{"resourceId": {"type": "organization", "id": "42"}}
+		]}`,
+		"/v2/folders/1234:getIamPolicy": `{"bindings": [{"role": "roles/container.developer", "members": ["group:devs@example.com"],
+			"condition": {"title": "expires-2026", "expression": "request.time < timestamp(\"2026-12-31T00:00:00Z\")"}}]}`,
+		"/v1/organizations/42:getIamPolicy": `{"bindings": [{"role": "roles/container.admin", "members": ["user:jane@example.com"]}]}`,
+	}
+
+	policyVersions := map[string]int64{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ":getIamPolicy") {
+			req := struct {
+				Options struct {
+					RequestedPolicyVersion int64 `json:"requestedPolicyVersion"`
+				} `json:"options"`
+			}{}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			policyVersions[r.URL.Path] = req.Options.RequestedPolicyVersion
+		}
+
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			http.Error(w, `{"error": {"code": 403, "message": "denied"}}`, http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	crmService, err := cloudresourcemanager.NewService(ctx, option.WithEndpoint(srv.URL+"/"), option.WithHTTPClient(srv.Client()))
+	assert.NoError(t, err)
+	foldersService, err := crmv2.NewService(ctx, option.WithEndpoint(srv.URL+"/"), option.WithHTTPClient(srv.Client()))
+	assert.NoError(t, err)
+
+	projectPolicy := &cloudresourcemanager.Policy{
+		Bindings: []*cloudresourcemanager.Binding{{
+			Role:    "roles/container.viewer",
+			Members: []string{"user:jane@example.com"},
+		}},
+	}
+
+	policies := loadGkeAncestorPolicies(ctx, crmService, foldersService, "example", projectPolicy)
+
+	levels := []string{}
+	for _, policy := range policies {
+		levels = append(levels, policy.Level)
+	}
+	assert.Equal(t, []string{"projects/example", "folders/1234", "organizations/42"}, levels, "Expected folders/5678 to be skipped as its policy can't be read")
+
+	l := genLister()
+	for _, policy := range policies {
+		l.loadGkeIamPolicy(policy)
+	}
+
+	assert.Equal(t, []simpleRole{{
+		Kind: "IAM",
+		Name: "gke-viewer",
+		Source: simpleRoleSource{
+			Kind:  "IAMRole",
+			Name:  "container.viewer",
+			Level: "projects/example",
+		},
+	}, {
+		Kind: "IAM",
+		Name: "gke-admin",
+		Source: simpleRoleSource{
+			Kind:  "IAMRole",
+			Name:  "container.admin",
+			Level: "organizations/42",
+		},
+	}}, l.rbacSubjectsByScope["jane@example.com"].RolesByScope[gkeIamScope])
+
+	assert.Equal(t, "folders/1234", l.rbacSubjectsByScope["devs@example.com"].RolesByScope[gkeIamScope][0].Source.Level)
+	assert.Equal(t, &iamCondition{
+		Title:      "expires-2026",
+		Expression: `request.time < timestamp("2026-12-31T00:00:00Z")`,
+	}, l.rbacSubjectsByScope["devs@example.com"].RolesByScope[gkeIamScope][0].Condition, "Expected folder binding conditions to be kept")
+	assert.Equal(t, map[string]int64{
+		"/v2/folders/1234:getIamPolicy":     3,
+		"/v2/folders/5678:getIamPolicy":     3,
+		"/v1/organizations/42:getIamPolicy": 3,
+	}, policyVersions, "Expected policy version 3 to be requested")
+
+	var buf bytes.Buffer
+	assert.NoError(t, l.printRbacBindings(&buf, "wide"))
+	assert.Contains(t, buf.String(), "IAMRole/container.admin on organizations/42")
+}
+
+func TestLoadGkeAncestorPoliciesWithoutAncestry(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": {"code": 403, "message": "denied"}}`, http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	crmService, err := cloudresourcemanager.NewService(ctx, option.WithEndpoint(srv.URL+"/"), option.WithHTTPClient(srv.Client()))
+	assert.NoError(t, err)
+	foldersService, err := crmv2.NewService(ctx, option.WithEndpoint(srv.URL+"/"), option.WithHTTPClient(srv.Client()))
+	assert.NoError(t, err)
+
+	policies := loadGkeAncestorPolicies(ctx, crmService, foldersService, "example", &cloudresourcemanager.Policy{})
+
+	assert.Len(t, policies, 1, "Expected only the project policy when the hierarchy can't be read")
+	assert.Equal(t, "projects/example", policies[0].Level)
+}
+
+func TestContainerPermissionRules(t *testing.T) {
+	rules := containerPermissionRules([]string{
+		"container.clusters.get",
+		"container.deployments.get",
+		"container.deployments.update",
+		"container.pods.exec",
+		"container.pods.getLogs",
+		"container.pods.list",
+		"container.pods.evict",
+		"container.pods.frobnicate",
+		"container.serviceAccounts.createToken",
+		"container.deployments.getScale",
+		"container.deployments.updateScale",
+		"container.thirdPartyObjects.list",
+		"container.thirdPartyObjects.delete",
+		"container.widgets.get",
+		"compute.instances.get",
+	})
+
+	assert.Equal(t, []roleRule{
+		{PolicyRule: rbacv1.PolicyRule{Verbs: []string{"list", "delete"}, APIGroups: []string{"*"}, Resources: []string{"*"}}, CustomResources: true},
+		{PolicyRule: rbacv1.PolicyRule{Verbs: []string{"get", "update", "patch"}, APIGroups: []string{"apps"}, Resources: []string{"deployments"}}},
+		{PolicyRule: rbacv1.PolicyRule{Verbs: []string{"get", "update", "patch"}, APIGroups: []string{"apps"}, Resources: []string{"deployments/scale"}}},
+		{PolicyRule: rbacv1.PolicyRule{Verbs: []string{"list"}, APIGroups: []string{""}, Resources: []string{"pods"}}},
+		{PolicyRule: rbacv1.PolicyRule{Verbs: []string{"create"}, APIGroups: []string{""}, Resources: []string{"pods/eviction"}}},
+		{PolicyRule: rbacv1.PolicyRule{Verbs: []string{"create"}, APIGroups: []string{""}, Resources: []string{"pods/exec"}}},
+		{PolicyRule: rbacv1.PolicyRule{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"pods/log"}}},
+		{PolicyRule: rbacv1.PolicyRule{Verbs: []string{"create"}, APIGroups: []string{""}, Resources: []string{"serviceaccounts/token"}}},
+		{PolicyRule: rbacv1.PolicyRule{Verbs: []string{"get"}, APIGroups: []string{"*"}, Resources: []string{"widgets"}}},
+	}, rules)
+
+	evict := parseAccessRequest("create", "pods", "eviction")
+	assert.True(t, evict.allowedByRule(rules[4].PolicyRule), "Expected container.pods.evict to allow creating pods/eviction")
+}
+
+func TestGkeIamRole(t *testing.T) {
+	_, ok := gkeIamRole("roles/storage.admin", []string{"storage.buckets.get"})
+	assert.False(t, ok, "Expected a role without container permissions to be left out")
+
+	sr, ok := gkeIamRole("projects/example/roles/deployer", []string{"container.clusters.get"})
+	assert.True(t, ok, "Expected a role with only GKE API permissions to be included")
+	assert.Equal(t, "projects/example/roles/deployer", sr.Name)
+	assert.Empty(t, sr.Rules)
+
+	sr, ok = gkeIamRole("roles/container.clusterViewer", []string{"container.clusters.list"})
+	assert.True(t, ok)
+	assert.Equal(t, simpleRoleSource{Kind: "IAMRole", Name: "container.clusterViewer"}, sr.Source)
+}
+
+func TestResolveGkeIamRoles(t *testing.T) {
+	requests := map[string]int{}
+	responses := map[string]string{
+		"/v1/projects/example/roles/deployer": `{"name": "projects/example/roles/deployer", "includedPermissions": ["container.deployments.update"]}`,
+		"/v1/organizations/42/roles/auditor":  `{"name": "organizations/42/roles/auditor", "includedPermissions": ["container.pods.list"]}`,
+		"/v1/roles/storage.admin":             `{"name": "roles/storage.admin", "includedPermissions": ["storage.buckets.get"]}`,
+		"/v1/roles/container.admin":           `{"name": "roles/container.admin", "includedPermissions": ["container.clusters.get", "container.secrets.delete"]}`,
+		"/v1/roles/container.clusterViewer":   `{"name": "roles/container.clusterViewer", "includedPermissions": ["container.clusters.list"]}`,
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			http.Error(w, `{"error": {"code": 403, "message": "denied"}}`, http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	iamService, err := iam.NewService(ctx, option.WithEndpoint(srv.URL+"/"), option.WithHTTPClient(srv.Client()))
+	assert.NoError(t, err)
+
+	policies := []gkeIamPolicy{{
+		Level: "projects/example",
+		Bindings: []*cloudresourcemanager.Binding{
+			{Role: "projects/example/roles/deployer", Members: []string{"user:jane@example.com"}},
+			{Role: "roles/storage.admin", Members: []string{"user:jane@example.com"}},
+			{Role: "roles/container.admin", Members: []string{"user:joe@example.com"}},
+			{Role: "roles/container.clusterViewer", Members: []string{"user:joe@example.com"}},
+			{Role: "projects/example/roles/missing", Members: []string{"user:joe@example.com"}},
+		},
+	}, {
+		Level: "organizations/42",
+		Bindings: []*cloudresourcemanager.Binding{
+			{Role: "organizations/42/roles/auditor", Members: []string{"group:audit@example.com"}},
+			{Role: "projects/example/roles/deployer", Members: []string{"group:devs@example.com"}},
+		},
+	}}
+
+	roles := resolveGkeIamRoles(ctx, iamService, policies)
+
+	assert.Len(t, roles, 4, "Expected only roles with container permissions")
+	assert.Contains(t, roles, "projects/example/roles/deployer")
+	assert.Contains(t, roles, "organizations/42/roles/auditor")
+	assert.Contains(t, roles, "roles/container.clusterViewer")
+	assert.Equal(t, 1, requests["/v1/projects/example/roles/deployer"], "Expected each role to be looked up once")
+	assert.Equal(t, simpleRole{
+		Kind: "IAM",
+		Name: "gke-admin",
+		Source: simpleRoleSource{
+			Kind: "IAMRole",
+			Name: "container.admin",
+		},
+		Rules: []roleRule{{PolicyRule: rbacv1.PolicyRule{
+			Verbs:     []string{"delete"},
+			APIGroups: []string{""},
+			Resources: []string{"secrets"},
+		}}},
+	}, roles["roles/container.admin"], "Expected known roles to keep their name and be given rules")
+
+	l := genLister()
+	l.gkeRoles = roles
+	for _, policy := range policies {
+		l.loadGkeIamPolicy(policy)
+	}
+
+	assert.Equal(t, []simpleRole{{
+		Kind: "IAM",
+		Name: "projects/example/roles/deployer",
+		Source: simpleRoleSource{
+			Kind:  "IAMRole",
+			Name:  "projects/example/roles/deployer",
+			Level: "projects/example",
+		},
+	}}, l.rbacSubjectsByScope["jane@example.com"].RolesByScope[gkeIamScope], "Expected rules to be left out without --show-rules")
+
+	l = genLister()
+	l.gkeRoles = roles
+	l.showRules = true
+	l.loadGkeIamPolicy(policies[1])
+
+	assert.Equal(t, []roleRule{{PolicyRule: rbacv1.PolicyRule{
+		Verbs:     []string{"list"},
+		APIGroups: []string{""},
+		Resources: []string{"pods"},
+	}}}, l.rbacSubjectsByScope["audit@example.com"].RolesByScope[gkeIamScope][0].Rules)
+}
+
+func TestParseIamMember(t *testing.T) {
+	tests := []struct {
+		member   string
+		expected iamMember
+	}{
+		{"user:jane@example.com", iamMember{Kind: "User", Name: "jane@example.com"}},
+		{"group:devs@example.com", iamMember{Kind: "Group", Name: "devs@example.com"}},
+		{"serviceAccount:ci@example.iam.gserviceaccount.com", iamMember{Kind: "ServiceAccount", Name: "ci@example.iam.gserviceaccount.com"}},
+		{"domain:example.com", iamMember{Kind: "Domain", Name: "example.com"}},
+		{"allUsers", iamMember{Kind: "Group", Name: "allUsers"}},
+		{"allAuthenticatedUsers", iamMember{Kind: "Group", Name: "allAuthenticatedUsers"}},
+		{"deleted:user:jane@example.com?uid=123456", iamMember{Kind: "User", Name: "jane@example.com?uid=123456", Deleted: true}},
+		{"deleted:serviceAccount:ci@example.iam.gserviceaccount.com?uid=42", iamMember{Kind: "ServiceAccount", Name: "ci@example.iam.gserviceaccount.com?uid=42", Deleted: true}},
+		{
+			"principal://iam.googleapis.com/locations/global/workforcePools/corp/subject/jane",
+			iamMember{Kind: "Principal", Name: "principal://iam.googleapis.com/locations/global/workforcePools/corp/subject/jane"},
+		},
+		{
+			"principalSet://iam.googleapis.com/locations/global/workforcePools/corp/group/devs",
+			iamMember{Kind: "PrincipalSet", Name: "principalSet://iam.googleapis.com/locations/global/workforcePools/corp/group/devs"},
+		},
+	}
+
+	for _, tt := range tests {
+		member, ok := parseIamMember(tt.member)
+		assert.True(t, ok, tt.member)
+		assert.Equal(t, tt.expected, member, tt.member)
+	}
+
+	_, ok := parseIamMember("user:")
+	assert.False(t, ok, "Expected a member without a name to be rejected")
+}
+
+func TestLoadGkeMembersAndConditions(t *testing.T) {
+	policy := gkeIamPolicy{
+		Level: "projects/example",
+		Bindings: []*cloudresourcemanager.Binding{{
+			Role:    "roles/container.viewer",
+			Members: []string{"allAuthenticatedUsers", "domain:example.com", "deleted:user:jane@example.com?uid=123456", "bogus"},
+		}, {
+			Role:    "roles/container.admin",
+			Members: []string{"user:joe@example.com"},
+			Condition: &cloudresourcemanager.Expr{
+				Title:      "expires-2026",
+				Expression: `request.time < timestamp("2026-12-31T00:00:00Z")`,
+			},
+		}},
+	}
+
+	l := genLister()
+	l.loadGkeIamPolicy(policy)
+
+	assert.Len(t, l.rbacSubjectsByScope, 4, "Expected every well formed member to be included")
+	assert.Equal(t, "Group", l.rbacSubjectsByScope["allAuthenticatedUsers"].Kind)
+	assert.Equal(t, "Domain", l.rbacSubjectsByScope["example.com"].Kind)
+	assert.Equal(t, "User", l.rbacSubjectsByScope["jane@example.com?uid=123456"].Missing, "Expected deleted members to be marked missing")
+	assert.Empty(t, l.rbacSubjectsByScope["joe@example.com"].Missing)
+
+	var buf bytes.Buffer
+	assert.NoError(t, l.printRbacBindings(&buf, ""))
+	assert.Contains(t, buf.String(), "IAM/gke-admin (condition: expires-2026)")
+	assert.Contains(t, buf.String(), "IAM/gke-viewer\n")
+}
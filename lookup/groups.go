@@ -0,0 +1,218 @@
+// Copyright 2018 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lookup
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// identity is a Kubernetes user or group that a subject acts as, and so
+// is granted every role bound to.
+type identity struct {
+	Kind string
+	Name string
+}
+
+// expandsMembership reports whether roles granted to groups, or to the
+// Kubernetes users IAM identities map to, should be added to the subjects
+// they apply to.
+func (l *lister) expandsMembership() bool {
+	return l.includeImplicitGroups || len(l.groupMap) > 0 || l.awsAuth != nil
+}
+
+// identitiesFor returns every identity a subject acts as besides itself,
+// through implicit groups, the group map, or aws-auth mappings.
+func (l *lister) identitiesFor(subjectKey, kind string) []identity {
+	groups := []string{}
+	if l.includeImplicitGroups {
+		groups = append(groups, implicitGroups(subjectKey, kind)...)
+	}
+	if kind == rbacv1.UserKind {
+		groups = append(groups, l.groupMap[subjectKey]...)
+	}
+
+	identities := []identity{}
+	if l.awsAuth != nil {
+		identities = append(identities, l.awsAuth.identitiesFor(subjectKey, kind, l.includeImplicitGroups)...)
+	}
+	for _, group := range groups {
+		identities = append(identities, identity{Kind: rbacv1.GroupKind, Name: group})
+	}
+
+	return identities
+}
+
+// addMemberSubject adds an empty subject that roles are only granted to
+// through its identities, if it matches the query and is not already there.
+func (l *lister) addMemberSubject(name, kind string) {
+	if _, exist := l.rbacSubjectsByScope[name]; exist {
+		return
+	}
+	if l.nameMatches(name) && l.kindMatches(kind) && !l.subjectExcluded(name) {
+		l.rbacSubjectsByScope[name] = rbacSubject{
+			Kind:         kind,
+			RolesByScope: make(map[string][]simpleRole),
+		}
+	}
+}
+
+// addMembershipRoles adds the roles bound to each identity a matching
+// subject acts as, recording the identity as the source's Via. Users in the
+// group map, mapped IAM identities and, with implicit groups, queried
+// ServiceAccounts and users are included even if no binding names them
+// directly. It expects bindings to already be cached.
+func (l *lister) addMembershipRoles() {
+	for user := range l.groupMap {
+		l.addMemberSubject(user, rbacv1.UserKind)
+	}
+
+	if l.includeImplicitGroups {
+		l.addQueriedMembers()
+	}
+
+	if l.awsAuth != nil {
+		for _, mapping := range l.awsAuth.Mappings {
+			l.addMemberSubject(mapping.ARN, mapping.Kind)
+		}
+		if arn, kind, ok := l.awsAuth.accountMapped(l.matcherQuery()); ok {
+			l.addMemberSubject(arn, kind)
+		}
+	}
+
+	wanted := make(map[identity]bool)
+	for subjectKey, rbacSubj := range l.rbacSubjectsByScope {
+		for _, id := range l.identitiesFor(subjectKey, rbacSubj.Kind) {
+			wanted[id] = true
+		}
+	}
+
+	granted := make(map[identity]rbacSubject)
+	grantedSubject := func(id identity) rbacSubject {
+		if rbacSubj, exist := granted[id]; exist {
+			return rbacSubj
+		}
+		rbacSubj := rbacSubject{
+			Kind:         id.Kind,
+			RolesByScope: make(map[string][]simpleRole),
+		}
+		granted[id] = rbacSubj
+		return rbacSubj
+	}
+
+	for _, roleBinding := range l.roleBindings.Items {
+		if !l.roleMatches(roleBinding.RoleRef.Kind, roleBinding.RoleRef.Name) || l.bindingExcluded(roleBinding.ObjectMeta) {
+			continue
+		}
+
+		for _, subject := range roleBinding.Subjects {
+			id := identity{Kind: subject.Kind, Name: subject.Name}
+			if wanted[id] {
+				rbacSubj := grantedSubject(id)
+				rbacSubj.addRoleBinding(&roleBinding)
+			}
+		}
+	}
+
+	for _, clusterRoleBinding := range l.clusterRoleBindings.Items {
+		if !l.roleMatches(clusterRoleBinding.RoleRef.Kind, clusterRoleBinding.RoleRef.Name) || l.bindingExcluded(clusterRoleBinding.ObjectMeta) {
+			continue
+		}
+
+		for _, subject := range clusterRoleBinding.Subjects {
+			id := identity{Kind: subject.Kind, Name: subject.Name}
+			if wanted[id] {
+				rbacSubj := grantedSubject(id)
+				rbacSubj.addClusterRoleBinding(&clusterRoleBinding)
+			}
+		}
+	}
+
+	for subjectKey, rbacSubj := range l.rbacSubjectsByScope {
+		for _, id := range l.identitiesFor(subjectKey, rbacSubj.Kind) {
+			for scope, simpleRoles := range granted[id].RolesByScope {
+				for _, sr := range simpleRoles {
+					sr.Source.Via = id.Name
+					rbacSubj.RolesByScope[scope] = append(rbacSubj.RolesByScope[scope], sr)
+				}
+			}
+		}
+
+		if len(rbacSubj.RolesByScope) == 0 {
+			delete(l.rbacSubjectsByScope, subjectKey)
+		}
+	}
+}
+
+// loadGroupMap reads a mapping of users to the groups they are members of.
+// CSV files have a user and group per row, anything else is read as a YAML
+// or JSON object of users to lists of groups.
+func loadGroupMap(path string) (map[string][]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return parseGroupMapCSV(f)
+	}
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	groupMap := make(map[string][]string)
+	if err := yaml.Unmarshal(data, &groupMap); err != nil {
+		return nil, err
+	}
+
+	return groupMap, nil
+}
+
+// parseGroupMapCSV reads user,group rows, skipping a user,group header if
+// there is one. Users in several groups are listed once per group.
+func parseGroupMapCSV(r io.Reader) (map[string][]string, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = 2
+	cr.TrimLeadingSpace = true
+
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	groupMap := make(map[string][]string)
+	for i, record := range records {
+		user, group := strings.TrimSpace(record[0]), strings.TrimSpace(record[1])
+		if i == 0 && strings.EqualFold(user, "user") && strings.EqualFold(group, "group") {
+			continue
+		}
+		if user == "" || group == "" {
+			return nil, fmt.Errorf("line %d: user and group are required", i+1)
+		}
+		groupMap[user] = append(groupMap[user], group)
+	}
+
+	return groupMap, nil
+}
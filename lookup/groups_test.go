@@ -0,0 +1,127 @@
+// Copyright 2018 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lookup
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestLoadGroupMap(t *testing.T) {
+	expected := map[string][]string{
+		"alice@example.com": {"sre", "payments"},
+		"bob@example.com":   {"payments"},
+	}
+
+	files := map[string]string{
+		"groups.yaml": "alice@example.com: [sre, payments]\nbob@example.com:\n- payments\n",
+		"groups.json": `{"alice@example.com": ["sre", "payments"], "bob@example.com": ["payments"]}`,
+		"groups.csv":  "user,group\nalice@example.com,sre\nalice@example.com, payments\nbob@example.com,payments\n",
+	}
+
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		assert.Nil(t, os.WriteFile(path, []byte(content), 0600), "Expected no error writing %s", name)
+
+		groupMap, err := loadGroupMap(path)
+		assert.Nil(t, err, "Expected no error loading %s", name)
+		assert.Equal(t, expected, groupMap, "Unexpected group map from %s", name)
+	}
+
+	_, err := parseGroupMapCSV(strings.NewReader("alice@example.com\n"))
+	assert.NotNil(t, err, "Expected an error for a row without a group")
+}
+
+func TestGroupMap(t *testing.T) {
+	l := genLister()
+	l.matcher = &nameMatcher{query: "example.com"}
+	l.groupMap = map[string][]string{
+		"alice@example.com": {"sre"},
+		"bob@example.com":   {"payments"},
+		"joe@example.com":   {"nobody"},
+	}
+
+	createRoleBindings(t, l)
+	createClusterRoleBindings(t, l)
+
+	groupBindings := []rbacv1.RoleBinding{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "sre-admin",
+			Namespace: "foo",
+		},
+		Subjects: []rbacv1.Subject{{
+			Name: "sre",
+			Kind: "Group",
+		}},
+		RoleRef: rbacv1.RoleRef{
+			Kind: "ClusterRole",
+			Name: "admin",
+		},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "bob-view",
+			Namespace: "payments",
+		},
+		Subjects: []rbacv1.Subject{{
+			Name: "bob@example.com",
+			Kind: "User",
+		}},
+		RoleRef: rbacv1.RoleRef{
+			Kind: "ClusterRole",
+			Name: "view",
+		},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "payments-edit",
+			Namespace: "payments",
+		},
+		Subjects: []rbacv1.Subject{{
+			Name: "payments",
+			Kind: "Group",
+		}},
+		RoleRef: rbacv1.RoleRef{
+			Kind: "ClusterRole",
+			Name: "edit",
+		},
+	}}
+
+	for _, roleBinding := range groupBindings {
+		_, err := l.clientset.RbacV1().RoleBindings(roleBinding.Namespace).Create(context.Background(), &roleBinding, metav1.CreateOptions{})
+		assert.Nil(t, err, "Expected no error creating role bindings")
+	}
+
+	loadAll(t, l)
+
+	assert.Equal(t, []string{"alice@example.com", "bob@example.com"}, l.subjectNames(), "Expected users without any roles to be left out")
+
+	var buf bytes.Buffer
+	err := l.printRbacBindings(&buf, "wide")
+	assert.Nil(t, err, "Expected no error printing")
+	assert.Equal(t, `SUBJECT                   SCOPE      ROLE                SOURCE
+User/alice@example.com    foo        ClusterRole/admin   RoleBinding/sre-admin (via sre)
+User/bob@example.com      payments   ClusterRole/edit    RoleBinding/payments-edit (via payments)
+User/bob@example.com      payments   ClusterRole/view    RoleBinding/bob-view
+`, buf.String())
+}
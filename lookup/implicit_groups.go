@@ -0,0 +1,79 @@
+// Copyright 2018 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lookup
+
+import (
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// Groups every authenticated user or ServiceAccount belongs to without
+// being listed as a member anywhere.
+const (
+	authenticatedGroup   = "system:authenticated"
+	serviceAccountsGroup = "system:serviceaccounts"
+)
+
+// implicitGroups returns the groups a subject is a member of just by
+// being a ServiceAccount or an authenticated user.
+func implicitGroups(subjectKey, kind string) []string {
+	switch kind {
+	case "ServiceAccount":
+		namespace, _, _ := strings.Cut(subjectKey, ":")
+		return []string{serviceAccountsGroup, serviceAccountsGroup + ":" + namespace, authenticatedGroup}
+	case "User":
+		return []string{authenticatedGroup}
+	default:
+		return nil
+	}
+}
+
+// isImplicitGroup reports whether group is one that subjects can be a
+// member of implicitly.
+func isImplicitGroup(group string) bool {
+	return group == authenticatedGroup || group == serviceAccountsGroup || strings.HasPrefix(group, serviceAccountsGroup+":")
+}
+
+// addQueriedMembers adds the ServiceAccounts matching the query by name, and
+// the queried user with --kind user, even if no binding names them, as they
+// may only be granted roles through their implicit groups. Without a query
+// only subjects named by bindings are listed, and the user is only added when
+// the query is a name rather than a pattern.
+func (l *lister) addQueriedMembers() {
+	query := l.matcherQuery()
+	if query == "" {
+		return
+	}
+
+	for subjectKey, serviceAccount := range l.serviceAccounts {
+		if _, exist := l.rbacSubjectsByScope[subjectKey]; exist {
+			continue
+		}
+		if len(l.namespaceFilter) > 0 && !contains(l.namespaceFilter, serviceAccount.Namespace) {
+			continue
+		}
+		if l.nameMatches(serviceAccount.Name) && l.kindMatches(rbacv1.ServiceAccountKind) && !l.subjectExcluded(subjectKey) {
+			l.rbacSubjectsByScope[subjectKey] = rbacSubject{
+				Kind:         rbacv1.ServiceAccountKind,
+				RolesByScope: make(map[string][]simpleRole),
+			}
+		}
+	}
+
+	if l.subjectKind == "user" && l.matcher.isLiteral() {
+		l.addMemberSubject(query, rbacv1.UserKind)
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2018 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lookup
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestImplicitGroups(t *testing.T) {
+	assert.Equal(t, []string{"system:serviceaccounts", "system:serviceaccounts:ci", "system:authenticated"}, implicitGroups("ci:deployer", "ServiceAccount"))
+	assert.Equal(t, []string{"system:authenticated"}, implicitGroups("joe", "User"))
+	assert.Nil(t, implicitGroups("admins", "Group"))
+}
+
+func TestIncludeImplicitGroups(t *testing.T) {
+	l := genLister()
+	l.includeImplicitGroups = true
+	l.matcher = &nameMatcher{query: "circleci"}
+
+	createRoleBindings(t, l)
+	createClusterRoleBindings(t, l)
+	createImplicitGroupBindings(t, l)
+
+	loadAll(t, l)
+
+	assert.Len(t, l.rbacSubjectsByScope, 2, "Expected the ServiceAccount and its namespace group")
+
+	var buf bytes.Buffer
+	err := l.printRbacBindings(&buf, "wide")
+	assert.Nil(t, err, "Expected no error printing")
+	assert.Equal(t, `SUBJECT                                  SCOPE          ROLE                           SOURCE
+ServiceAccount/circleci:circleci         cluster-wide   ClusterRole/cluster-admin      ClusterRoleBinding/circleci-cluster-admin
+ServiceAccount/circleci:circleci         cluster-wide   ClusterRole/system:discovery   ClusterRoleBinding/system:discovery (via system:authenticated)
+ServiceAccount/circleci:circleci         build          ClusterRole/view               RoleBinding/circleci-view (via system:serviceaccounts:circleci)
+ServiceAccount/circleci:circleci         three          ClusterRole/cluster-admin      RoleBinding/testing-sa
+ServiceAccount/circleci:circleci         two            ClusterRole/cluster-admin      RoleBinding/testing-sa
+Group/system:serviceaccounts:circleci    build          ClusterRole/view               RoleBinding/circleci-view
+`, buf.String())
+}
+
+func TestIncludeImplicitGroupsOtherNamespace(t *testing.T) {
+	l := genLister()
+	l.includeImplicitGroups = true
+	l.matcher = &nameMatcher{query: "joe"}
+
+	createRoleBindings(t, l)
+	createClusterRoleBindings(t, l)
+	createImplicitGroupBindings(t, l)
+
+	loadAll(t, l)
+
+	roles := l.rbacSubjectsByScope["joe"].RolesByScope
+	assert.Len(t, roles, 2, "Expected only cluster-wide and foo scopes for a user")
+	assert.Len(t, roles["cluster-wide"], 2, "Expected the direct and system:authenticated cluster roles")
+}
+
+func TestIncludeImplicitGroupsWithoutDirectBindings(t *testing.T) {
+	l := genLister()
+	l.includeImplicitGroups = true
+	l.matcher = &nameMatcher{query: "builder", rawQuery: "builder"}
+
+	createImplicitGroupBindings(t, l)
+
+	serviceAccount := corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "builder",
+			Namespace: "circleci",
+		},
+	}
+	_, err := l.clientset.CoreV1().ServiceAccounts("circleci").Create(context.Background(), &serviceAccount, metav1.CreateOptions{})
+	assert.Nil(t, err, "Expected no error creating service accounts")
+
+	loadAll(t, l)
+
+	assert.Len(t, l.rbacSubjectsByScope, 1, "Expected the ServiceAccount even though no binding names it")
+	roles := l.rbacSubjectsByScope["circleci:builder"].RolesByScope
+	assert.Equal(t, "system:serviceaccounts:circleci", roles["build"][0].Source.Via)
+	assert.Equal(t, "system:authenticated", roles["cluster-wide"][0].Source.Via)
+}
+
+func TestIncludeImplicitGroupsQueriedUser(t *testing.T) {
+	l := genLister()
+	l.includeImplicitGroups = true
+	l.matcher = &nameMatcher{query: "jane@example.com", rawQuery: "jane@example.com", mode: "exact"}
+
+	createImplicitGroupBindings(t, l)
+
+	loadAll(t, l)
+	assert.Len(t, l.rbacSubjectsByScope, 0, "Expected users to only be added with --kind user")
+
+	l.subjectKind = "user"
+	loadAll(t, l)
+	assert.Equal(t, "system:authenticated", l.rbacSubjectsByScope["jane@example.com"].RolesByScope["cluster-wide"][0].Source.Via)
+
+	patterns := map[string]string{
+		"glob":     "*@example.com",
+		"regex":    ".*@example.com",
+		"prefix":   "jane",
+		"contains": "example.com",
+	}
+	for mode, query := range patterns {
+		l.rbacSubjectsByScope = make(map[string]rbacSubject)
+		matcher, err := newNameMatcher(query, mode, false)
+		assert.NoError(t, err)
+		l.matcher = matcher
+
+		loadAll(t, l)
+		assert.Len(t, l.rbacSubjectsByScope, 0, "Expected the %s pattern %q not to be added as a user", mode, query)
+	}
+}
+
+func TestIncludeImplicitGroupsForbidden(t *testing.T) {
+	l := genLister()
+	l.includeImplicitGroups = true
+	l.matcher = &nameMatcher{query: "circleci"}
+
+	createRoleBindings(t, l)
+	l.clientset.(*testclient.Clientset).PrependReactor("list", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(corev1.Resource("serviceaccounts"), "", nil)
+	})
+
+	loadAll(t, l)
+
+	assert.Contains(t, l.rbacSubjectsByScope, "circleci:circleci", "Expected the lookup to continue without ServiceAccounts")
+}
+
+func createImplicitGroupBindings(t *testing.T, l lister) {
+	clusterRoleBinding := rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "system:discovery",
+		},
+		Subjects: []rbacv1.Subject{{
+			Name: "system:authenticated",
+			Kind: "Group",
+		}},
+		RoleRef: rbacv1.RoleRef{
+			Kind: "ClusterRole",
+			Name: "system:discovery",
+		},
+	}
+	_, err := l.clientset.RbacV1().ClusterRoleBindings().Create(context.Background(), &clusterRoleBinding, metav1.CreateOptions{})
+	assert.Nil(t, err, "Expected no error creating cluster role bindings")
+
+	roleBindings := []rbacv1.RoleBinding{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "circleci-view",
+			Namespace: "build",
+		},
+		Subjects: []rbacv1.Subject{{
+			Name: "system:serviceaccounts:circleci",
+			Kind: "Group",
+		}},
+		RoleRef: rbacv1.RoleRef{
+			Kind: "ClusterRole",
+			Name: "view",
+		},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "other-view",
+			Namespace: "build",
+		},
+		Subjects: []rbacv1.Subject{{
+			Name: "system:serviceaccounts:other",
+			Kind: "Group",
+		}},
+		RoleRef: rbacv1.RoleRef{
+			Kind: "ClusterRole",
+			Name: "view",
+		},
+	}}
+
+	for _, roleBinding := range roleBindings {
+		_, err := l.clientset.RbacV1().RoleBindings(roleBinding.Namespace).Create(context.Background(), &roleBinding, metav1.CreateOptions{})
+		assert.Nil(t, err, "Expected no error creating role bindings")
+	}
+}
@@ -15,9 +15,14 @@
 package lookup
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
 
@@ -25,10 +30,234 @@ import (
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 )
 
-// List outputs rbac bindings where subject names match given string
-func List(args []string, kubeConfig, kubeContext, outputFormat, subjectKind string, enableGke bool) {
+// Options configures how List loads and prints RBAC bindings
+type Options struct {
+	KubeConfig     string
+	KubeContext    string
+	OutputFormat   string
+	SubjectKind    string
+	SortBy         string
+	MatchMode      string
+	Role           string
+	Selector       string
+	Exclude        []string
+	QueryFile      string
+	Namespaces     []string
+	IgnoreCase     bool
+	ShowRules      bool
+	CheckRefs      bool
+	NamespacedOnly bool
+	ExcludeSystem  bool
+	ImplicitGroups bool
+	GroupMapFile   string
+	EnableGke      bool
+	EnableEks      bool
+	EnableAks      bool
 
-	clientConfig := getClientConfig(kubeConfig, kubeContext)
+	// AADDirectoryFiles are Azure AD directory exports used to resolve
+	// object IDs when EnableAks is set, unless AADResolver is given.
+	AADDirectoryFiles []string
+	AADResolver       AADResolver
+}
+
+// List outputs rbac bindings where subject names match given string. When
+// opts.QueryFile is set or there is more than one query, bindings are listed
+// once and the results are grouped by query, however many queries there are.
+func List(args []string, opts Options) {
+	queries := args
+	if opts.QueryFile != "" {
+		fileQueries, err := readQueryFile(opts.QueryFile)
+		if err != nil {
+			fmt.Printf("Error reading queries from %s: %v\n", opts.QueryFile, err)
+			os.Exit(1)
+		}
+		queries = append(queries, fileQueries...)
+
+		if len(queries) == 0 {
+			fmt.Printf("No queries found in %s\n", opts.QueryFile)
+			os.Exit(1)
+		}
+	}
+
+	if opts.QueryFile != "" || len(queries) > 1 {
+		listQueries(queries, opts)
+		return
+	}
+
+	filter := ""
+	if len(queries) > 0 {
+		filter = queries[0]
+	}
+
+	l := newLister(filter, opts)
+
+	loadErr := l.loadAll()
+	if loadErr != nil {
+		fmt.Printf("Error loading RBAC Bindings: %v\n", loadErr)
+		os.Exit(4)
+	}
+
+	printErr := l.printRbacBindings(os.Stdout, opts.OutputFormat)
+	if printErr != nil {
+		fmt.Printf("Error printing RBAC Bindings: %v\n", printErr)
+		os.Exit(5)
+	}
+}
+
+func listQueries(queries []string, opts Options) {
+	l := newLister("", opts)
+
+	loadErr := l.prefetch()
+
+	listers := make([]lister, 0, len(queries))
+	for _, query := range queries {
+		if loadErr != nil {
+			break
+		}
+
+		matcher, err := newNameMatcher(query, opts.MatchMode, opts.IgnoreCase)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		ql := l.forQuery(query, matcher)
+		loadErr = ql.loadAll()
+		listers = append(listers, ql)
+	}
+
+	if loadErr != nil {
+		fmt.Printf("Error loading RBAC Bindings: %v\n", loadErr)
+		os.Exit(4)
+	}
+
+	printErr := printQueryResults(os.Stdout, opts.OutputFormat, listers)
+	if printErr != nil {
+		fmt.Printf("Error printing RBAC Bindings: %v\n", printErr)
+		os.Exit(5)
+	}
+}
+
+func readQueryFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return readQueries(f)
+}
+
+// readQueries reads one subject query per line, skipping blank lines and
+// lines starting with #.
+func readQueries(r io.Reader) ([]string, error) {
+	queries := []string{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		queries = append(queries, line)
+	}
+
+	return queries, scanner.Err()
+}
+
+// WhoCan outputs rbac bindings that allow verb on resource, where resource
+// is TYPE[.GROUP][/NAME] or a non-resource URL. If opts.Namespaces is set,
+// only bindings that apply in those namespaces are included.
+func WhoCan(verb, resource, subresource string, opts Options) {
+	l := newLister("", opts)
+	l.showRules = true
+
+	loadErr := l.loadAll()
+	if loadErr != nil {
+		fmt.Printf("Error loading RBAC Bindings: %v\n", loadErr)
+		os.Exit(4)
+	}
+
+	req := resolveAPIGroup(parseAccessRequest(verb, resource, subresource), l.clientset.Discovery())
+	l.filterByAccess(req)
+
+	printErr := l.printRbacBindings(os.Stdout, opts.OutputFormat)
+	if printErr != nil {
+		fmt.Printf("Error printing RBAC Bindings: %v\n", printErr)
+		os.Exit(5)
+	}
+}
+
+// Orphans outputs rbac bindings for ServiceAccounts where subject names
+// match given string and either the ServiceAccount or its namespace no
+// longer exists
+func Orphans(args []string, opts Options) {
+	filter := ""
+	if len(args) > 0 {
+		filter = args[0]
+	}
+
+	l := newLister(filter, opts)
+	l.orphans = true
+
+	loadErr := l.loadAll()
+	if loadErr == nil {
+		loadErr = l.loadServiceAccounts()
+	}
+	if loadErr == nil {
+		loadErr = l.loadNamespaces()
+	}
+	if loadErr != nil {
+		fmt.Printf("Error loading RBAC Bindings: %v\n", loadErr)
+		os.Exit(4)
+	}
+
+	l.filterOrphans()
+
+	printErr := l.printRbacBindings(os.Stdout, opts.OutputFormat)
+	if printErr != nil {
+		fmt.Printf("Error printing RBAC Bindings: %v\n", printErr)
+		os.Exit(5)
+	}
+}
+
+func newLister(filter string, opts Options) lister {
+	matcher, err := newNameMatcher(filter, opts.MatchMode, opts.IgnoreCase)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	var roleFilter *roleMatcher
+	if opts.Role != "" {
+		roleFilter, err = newRoleMatcher(opts.Role)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	exclude, err := newExcludeMatchers(opts.Exclude, opts.IgnoreCase)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	var groupMap map[string][]string
+	if opts.GroupMapFile != "" {
+		groupMap, err = loadGroupMap(opts.GroupMapFile)
+		if err != nil {
+			fmt.Printf("Error reading group map %s: %v\n", opts.GroupMapFile, err)
+			os.Exit(1)
+		}
+	}
+
+	if _, err := labels.Parse(opts.Selector); err != nil {
+		fmt.Printf("Invalid selector %q: %v\n", opts.Selector, err)
+		os.Exit(1)
+	}
+
+	clientConfig := getClientConfig(opts.KubeConfig, opts.KubeContext)
 
 	kubeconfig, err := clientConfig.ClientConfig()
 	if err != nil {
@@ -42,36 +271,70 @@ func List(args []string, kubeConfig, kubeContext, outputFormat, subjectKind stri
 		os.Exit(2)
 	}
 
-	filter := ""
-	if len(args) > 0 {
-		filter = args[0]
+	dynamicClient, err := dynamic.NewForConfig(kubeconfig)
+	if err != nil {
+		fmt.Printf("Error generating Kubernetes dynamic client from kubeconfig: %v\n", err)
+		os.Exit(2)
 	}
 
 	l := lister{
-		filter:              filter,
-		subjectKind:         subjectKind,
-		clientset:           clientset,
-		rbacSubjectsByScope: make(map[string]rbacSubject),
+		matcher:               matcher,
+		roleFilter:            roleFilter,
+		labelSelector:         opts.Selector,
+		excludeSystem:         opts.ExcludeSystem,
+		exclude:               exclude,
+		includeImplicitGroups: opts.ImplicitGroups,
+		groupMap:              groupMap,
+		eks:                   opts.EnableEks,
+		subjectKind:           opts.SubjectKind,
+		sortBy:                opts.SortBy,
+		showRules:             opts.ShowRules,
+		checkRefs:             opts.CheckRefs,
+		namespaceFilter:       opts.Namespaces,
+		namespacedOnly:        opts.NamespacedOnly,
+		clientset:             clientset,
+		dynamicClient:         dynamicClient,
+		rbacSubjectsByScope:   make(map[string]rbacSubject),
 	}
 
-	if enableGke {
+	if opts.EnableAks {
 		rawConfig, err := clientConfig.RawConfig()
 		if err != nil {
 			fmt.Printf("Error getting Kubernetes raw config: %v\n", err)
 			os.Exit(3)
 		}
 
-		ci := getClusterInfo(&rawConfig, kubeContext)
-		l.gkeParsedProjectName = ci.ParsedProjectName
+		if ci := getAksClusterInfo(&rawConfig, opts.KubeContext); ci.ClusterName == "" {
+			fmt.Fprintln(os.Stderr, "Warning: the current context does not appear to be an AKS cluster")
+		}
+
+		l.aadResolver = opts.AADResolver
+		if l.aadResolver == nil {
+			if len(opts.AADDirectoryFiles) == 0 {
+				fmt.Println("AKS integration requires an Azure AD directory export, see --aad-directory")
+				os.Exit(1)
+			}
+
+			l.aadResolver, err = NewAADDirectory(opts.AADDirectoryFiles)
+			if err != nil {
+				fmt.Printf("Error reading Azure AD directory: %v\n", err)
+				os.Exit(1)
+			}
+		}
 	}
 
-	loadErr := l.loadAll()
-	if loadErr != nil {
-		fmt.Printf("Error loading RBAC Bindings: %v\n", loadErr)
-		os.Exit(4)
+	if opts.EnableGke {
+		rawConfig, err := clientConfig.RawConfig()
+		if err != nil {
+			fmt.Printf("Error getting Kubernetes raw config: %v\n", err)
+			os.Exit(3)
+		}
+
+		ci := getClusterInfo(&rawConfig, opts.KubeContext)
+		l.gkeParsedProjectName = ci.ParsedProjectName
 	}
 
-	l.printRbacBindings(outputFormat)
+	return l
 }
 
 func getClientConfig(kubeConfig, kubeContext string) clientcmd.ClientConfig {
@@ -0,0 +1,88 @@
+// Copyright 2018 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lookup
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestReadQueries(t *testing.T) {
+	queries, err := readQueries(strings.NewReader("joe\n\n  # leavers\n  sue  \ncircleci\n"))
+
+	assert.Nil(t, err, "Expected no error reading queries")
+	assert.Equal(t, []string{"joe", "sue", "circleci"}, queries)
+}
+
+func TestPrintQueryResults(t *testing.T) {
+	l := genLister()
+
+	createRoleBindings(t, l)
+	createClusterRoleBindings(t, l)
+
+	assert.Nil(t, l.prefetch(), "Expected no error prefetching bindings")
+
+	// Queries are matched against the prefetched bindings, so a deletion after
+	// prefetch is not seen.
+	err := l.clientset.RbacV1().ClusterRoleBindings().Delete(context.Background(), "circleci-cluster-admin", metav1.DeleteOptions{})
+	assert.Nil(t, err, "Expected no error deleting cluster role binding")
+
+	listers := []lister{}
+	for _, query := range []string{"joe", "circleci", "nobody"} {
+		ql := l.forQuery(query, &nameMatcher{query: query})
+		loadAll(t, ql)
+		listers = append(listers, ql)
+	}
+
+	var buf bytes.Buffer
+	err = printQueryResults(&buf, "", listers)
+	assert.Nil(t, err, "Expected no error printing")
+	assert.Equal(t, `QUERY: joe
+SUBJECT   SCOPE          ROLE
+joe       cluster-wide   ClusterRole/bar
+joe       foo            Role/bar
+
+QUERY: circleci
+SUBJECT              SCOPE          ROLE
+circleci:circleci    cluster-wide   ClusterRole/cluster-admin
+circleci:circleci    three          ClusterRole/cluster-admin
+circleci:circleci    two            ClusterRole/cluster-admin
+
+QUERY: nobody
+No RBAC Bindings found
+`, buf.String())
+
+	buf.Reset()
+	err = printQueryResults(&buf, "csv", listers)
+	assert.Nil(t, err, "Expected no error printing")
+	assert.Equal(t, `QUERY,SUBJECT,SUBJECT KIND,SCOPE,ROLE KIND,ROLE NAME,SOURCE KIND,SOURCE NAME,SOURCE LEVEL,VIA,CONDITION
+joe,joe,User,cluster-wide,ClusterRole,bar,ClusterRoleBinding,testing,,,
+joe,joe,User,foo,Role,bar,RoleBinding,testing,,,
+circleci,circleci:circleci,ServiceAccount,cluster-wide,ClusterRole,cluster-admin,ClusterRoleBinding,circleci-cluster-admin,,,
+circleci,circleci:circleci,ServiceAccount,three,ClusterRole,cluster-admin,RoleBinding,testing-sa,,,
+circleci,circleci:circleci,ServiceAccount,two,ClusterRole,cluster-admin,RoleBinding,testing-sa,,,
+`, buf.String())
+
+	buf.Reset()
+	err = printQueryResults(&buf, "jsonpath={range .queries[*]}{.query}={.subjects[*].name};{end}", listers)
+	assert.Nil(t, err, "Expected no error printing")
+	assert.Equal(t, `joe=joe;circleci=circleci:circleci;nobody=;`, buf.String())
+}
@@ -17,15 +17,18 @@ package lookup
 import (
 	"context"
 	"fmt"
-	"os"
+	"io"
 	"sort"
 	"strings"
 	"text/tabwriter"
 
 	"google.golang.org/api/cloudresourcemanager/v1"
 
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 
 	// Required for different auth providers like GKE, OIDC
@@ -33,14 +36,66 @@ import (
 )
 
 type lister struct {
-	clientset            kubernetes.Interface
-	filter               string
-	gkeParsedProjectName string
-	subjectKind          string
-	rbacSubjectsByScope  map[string]rbacSubject
+	clientset             kubernetes.Interface
+	matcher               *nameMatcher
+	roleFilter            *roleMatcher
+	labelSelector         string
+	excludeSystem         bool
+	exclude               []*nameMatcher
+	includeImplicitGroups bool
+	groupMap              map[string][]string
+	dynamicClient         dynamic.Interface
+	openshiftLoaded       bool
+	eks                   bool
+	awsAuth               *awsAuth
+	aadResolver           AADResolver
+	aadIdentities         map[string]AADIdentity
+	gkeParsedProjectName  string
+	subjectKind           string
+	sortBy                string
+	showRules             bool
+	checkRefs             bool
+	orphans               bool
+	namespaceFilter       []string
+	namespacedOnly        bool
+	query                 string
+	rbacSubjectsByScope   map[string]rbacSubject
+	roleBindings          *rbacv1.RoleBindingList
+	clusterRoleBindings   *rbacv1.ClusterRoleBindingList
+	gkePolicies           []gkeIamPolicy
+	gkeRoles              map[string]simpleRole
+	workloadIdentities    []workloadIdentity
+	roles                 map[string]rbacv1.Role
+	clusterRoles          map[string]rbacv1.ClusterRole
+	serviceAccounts       map[string]corev1.ServiceAccount
+	namespaces            map[string]bool
 }
 
 func (l *lister) loadAll() error {
+	if err := l.loadAADIdentities(); err != nil {
+		return err
+	}
+
+	if err := l.loadOpenShiftGroups(); err != nil {
+		return err
+	}
+
+	if err := l.loadAwsAuth(); err != nil {
+		return err
+	}
+
+	if l.expandsMembership() {
+		if err := l.cacheBindings(); err != nil {
+			return err
+		}
+	}
+
+	if l.includeImplicitGroups {
+		if err := l.loadServiceAccountsOnce("ServiceAccounts not named by any binding"); err != nil {
+			return err
+		}
+	}
+
 	rbErr := l.loadRoleBindings()
 
 	if rbErr != nil {
@@ -54,69 +109,278 @@ func (l *lister) loadAll() error {
 	}
 
 	if l.gkeParsedProjectName != "" {
-		policy, gkeErr := loadGkeIAMPolicy(l.gkeParsedProjectName)
+		if err := l.loadGkeIamOnce(); err != nil {
+			return err
+		}
+
+		for _, policy := range l.gkePolicies {
+			l.loadGkeIamPolicy(policy)
+		}
+
+		l.addWorkloadIdentityRoles()
+	}
+
+	if l.expandsMembership() {
+		l.addMembershipRoles()
+	}
+
+	if l.showRules || l.checkRefs {
+		if err := l.loadRolesOnce(); err != nil {
+			return err
+		}
+
+		l.resolveRules()
+	}
+
+	return nil
+}
+
+// prefetch lists everything loadAll needs up front, so that it can be run
+// for several queries without listing bindings again each time.
+func (l *lister) prefetch() error {
+	if err := l.cacheBindings(); err != nil {
+		return err
+	}
+
+	if err := l.loadAADIdentities(); err != nil {
+		return err
+	}
+
+	if err := l.loadOpenShiftGroups(); err != nil {
+		return err
+	}
+
+	if err := l.loadAwsAuth(); err != nil {
+		return err
+	}
+
+	if l.includeImplicitGroups {
+		if err := l.loadServiceAccountsOnce("ServiceAccounts not named by any binding"); err != nil {
+			return err
+		}
+	}
+
+	if l.gkeParsedProjectName != "" {
+		if err := l.loadGkeIamOnce(); err != nil {
+			return err
+		}
+	}
+
+	if l.showRules || l.checkRefs {
+		return l.loadRolesOnce()
+	}
 
-		if gkeErr != nil {
-			return gkeErr
+	return nil
+}
+
+// cacheBindings lists RoleBindings and ClusterRoleBindings unless they
+// already have been, so they can be matched more than once.
+func (l *lister) cacheBindings() error {
+	if l.roleBindings == nil {
+		roleBindings, err := l.listRoleBindings()
+		if err != nil {
+			return err
 		}
+		l.roleBindings = roleBindings
+	}
 
-		l.loadGkeIamPolicy(policy)
+	if l.clusterRoleBindings == nil {
+		clusterRoleBindings, err := l.listClusterRoleBindings()
+		if err != nil {
+			return err
+		}
+		l.clusterRoleBindings = clusterRoleBindings
 	}
 
 	return nil
 }
 
-func (l *lister) printRbacBindings(outputFormat string) {
+// forQuery returns a copy of the lister for a different subject query,
+// sharing anything already listed.
+func (l lister) forQuery(query string, matcher *nameMatcher) lister {
+	l.query = query
+	l.matcher = matcher
+	l.rbacSubjectsByScope = make(map[string]rbacSubject)
+	return l
+}
+
+func (l *lister) printRbacBindings(w io.Writer, outputFormat string) error {
+	format, arg, _ := strings.Cut(outputFormat, "=")
+
+	switch format {
+	case "json":
+		return printJSON(w, l.outputDocument())
+	case "yaml":
+		return printYAML(w, l.outputDocument())
+	case "csv":
+		return printDelimited(w, ',', l.outputRows(), false)
+	case "tsv":
+		return printDelimited(w, '\t', l.outputRows(), false)
+	case "go-template", "go-template-file", "jsonpath":
+		return printTemplate(w, format, arg, l.outputDocument())
+	case "custom-columns":
+		return printCustomColumns(w, arg, l.outputRows())
+	}
+
+	return l.printTable(w, outputFormat)
+}
+
+func (l *lister) printTable(w io.Writer, outputFormat string) error {
 	if len(l.rbacSubjectsByScope) < 1 {
-		fmt.Println("No RBAC Bindings found")
-		return
+		fmt.Fprintln(w, "No RBAC Bindings found")
+		return nil
+	}
+
+	switch outputFormat {
+	case "roles":
+		return l.printRoleTable(w)
+	case "bindings":
+		return l.printBindingTable(w)
+	}
+
+	tw := new(tabwriter.Writer)
+	tw.Init(w, 0, 8, 2, ' ', 0)
+
+	header := "SUBJECT\t SCOPE\t ROLE"
+	if outputFormat == "wide" {
+		header += "\t SOURCE"
+		if l.checkRefs {
+			header += "\t REF"
+		}
+	}
+	if l.orphans {
+		header += "\t MISSING"
+	}
+	if l.showRules {
+		header += "\t RULES"
 	}
+	fmt.Fprintln(tw, header)
+
+	for _, row := range l.outputRows() {
+		var line string
+		role := fmt.Sprintf("%s/%s%s", row.Role.Kind, row.Role.Name, row.Role.conditionSuffix())
+		if outputFormat == "wide" {
+			if row.Role.Aggregated {
+				role += " (aggregated)"
+			}
+			line = fmt.Sprintf("%s \t %s\t %s\t %s", row.qualifiedSubject(), row.Scope, role, row.Role.Source)
+			if l.checkRefs {
+				line += "\t " + refStatus(row.Role)
+			}
+		} else {
+			if l.checkRefs && row.Role.Missing {
+				role += " (missing)"
+			}
+			line = fmt.Sprintf("%s \t %s\t %s", row.subjectName(), row.Scope, role)
+		}
 
+		if l.orphans {
+			line += "\t " + row.SubjectMissing
+		}
+
+		if !l.showRules {
+			fmt.Fprintln(tw, line)
+			continue
+		}
+
+		if len(row.Role.Rules) == 0 {
+			fmt.Fprintf(tw, "%s\t <none>\n", line)
+			continue
+		}
+
+		// Additional rules are listed on their own lines below the role
+		padding := strings.Repeat("\t", strings.Count(line, "\t"))
+		for i, rule := range row.Role.Rules {
+			if i == 0 {
+				fmt.Fprintf(tw, "%s\t %s\n", line, formatRoleRule(rule))
+			} else {
+				fmt.Fprintf(tw, "%s\t %s\n", padding, formatRoleRule(rule))
+			}
+		}
+	}
+	return tw.Flush()
+}
+
+func (l *lister) subjectNames() []string {
 	names := make([]string, 0, len(l.rbacSubjectsByScope))
 	for name := range l.rbacSubjectsByScope {
 		names = append(names, name)
 	}
 	sort.Strings(names)
+	return names
+}
 
-	w := new(tabwriter.Writer)
-	w.Init(os.Stdout, 0, 8, 2, ' ', 0)
-
-	if outputFormat == "wide" {
-		fmt.Fprintln(w, "SUBJECT\t SCOPE\t ROLE\t SOURCE")
-	} else {
-		fmt.Fprintln(w, "SUBJECT\t SCOPE\t ROLE")
+// scopedNamespaces returns the namespaces to list namespaced objects in,
+// where metav1.NamespaceAll means every namespace.
+func (l *lister) scopedNamespaces() []string {
+	if len(l.namespaceFilter) == 0 {
+		return []string{metav1.NamespaceAll}
 	}
+	return l.namespaceFilter
+}
 
-	for _, subjectName := range names {
-		rbacSubject := l.rbacSubjectsByScope[subjectName]
-		for scope, simpleRoles := range rbacSubject.RolesByScope {
-			for _, simpleRole := range simpleRoles {
-				if outputFormat == "wide" {
-					fmt.Fprintf(w, "%s/%s \t %s\t %s/%s\t %s/%s\n", rbacSubject.Kind, subjectName, scope, simpleRole.Kind, simpleRole.Name, simpleRole.Source.Kind, simpleRole.Source.Name)
-				} else {
-					fmt.Fprintf(w, "%s \t %s\t %s/%s\n", subjectName, scope, simpleRole.Kind, simpleRole.Name)
-				}
-			}
+// listRoleBindings lists RoleBindings in each namespace being looked up
+// rather than across the cluster when namespaces are given, so that only
+// namespaced list permissions are needed.
+func (l *lister) listRoleBindings() (*rbacv1.RoleBindingList, error) {
+	roleBindings := &rbacv1.RoleBindingList{}
+
+	for _, namespace := range l.scopedNamespaces() {
+		list, err := l.clientset.RbacV1().RoleBindings(namespace).List(context.Background(), metav1.ListOptions{LabelSelector: l.labelSelector})
+
+		if err != nil {
+			fmt.Println("Error loading role bindings")
+			return nil, err
 		}
+
+		roleBindings.Items = append(roleBindings.Items, list.Items...)
 	}
-	w.Flush()
+
+	return roleBindings, nil
 }
 
-func (l *lister) loadRoleBindings() error {
-	roleBindings, err := l.clientset.RbacV1().RoleBindings("").List(context.Background(), metav1.ListOptions{})
+// listClusterRoleBindings lists ClusterRoleBindings, which apply in every
+// namespace, unless only namespaced bindings were asked for.
+func (l *lister) listClusterRoleBindings() (*rbacv1.ClusterRoleBindingList, error) {
+	if l.namespacedOnly {
+		return &rbacv1.ClusterRoleBindingList{}, nil
+	}
+
+	clusterRoleBindings, err := l.clientset.RbacV1().ClusterRoleBindings().List(context.Background(), metav1.ListOptions{LabelSelector: l.labelSelector})
 
 	if err != nil {
-		fmt.Println("Error loading role bindings")
-		return err
+		fmt.Println("Error loading cluster role bindings")
+		return nil, err
+	}
+
+	return clusterRoleBindings, nil
+}
+
+func (l *lister) loadRoleBindings() error {
+	roleBindings := l.roleBindings
+	if roleBindings == nil {
+		var err error
+		roleBindings, err = l.listRoleBindings()
+
+		if err != nil {
+			return err
+		}
 	}
 
 	for _, roleBinding := range roleBindings.Items {
+		if !l.roleMatches(roleBinding.RoleRef.Kind, roleBinding.RoleRef.Name) || l.bindingExcluded(roleBinding.ObjectMeta) {
+			continue
+		}
+
 		for _, subject := range roleBinding.Subjects {
 			if l.nameMatches(subject.Name) && l.kindMatches(subject.Kind) {
 				subjectKey := subject.Name
 				if subject.Kind == "ServiceAccount" {
 					subjectKey = fmt.Sprintf("%s:%s", subject.Namespace, subject.Name)
 				}
+				if l.subjectExcluded(subjectKey) {
+					continue
+				}
 				if rbacSubj, exist := l.rbacSubjectsByScope[subjectKey]; exist {
 					rbacSubj.addRoleBinding(&roleBinding)
 				} else {
@@ -136,20 +400,30 @@ func (l *lister) loadRoleBindings() error {
 }
 
 func (l *lister) loadClusterRoleBindings() error {
-	clusterRoleBindings, err := l.clientset.RbacV1().ClusterRoleBindings().List(context.Background(), metav1.ListOptions{})
+	clusterRoleBindings := l.clusterRoleBindings
+	if clusterRoleBindings == nil {
+		var err error
+		clusterRoleBindings, err = l.listClusterRoleBindings()
 
-	if err != nil {
-		fmt.Println("Error loading cluster role bindings")
-		return err
+		if err != nil {
+			return err
+		}
 	}
 
 	for _, clusterRoleBinding := range clusterRoleBindings.Items {
+		if !l.roleMatches(clusterRoleBinding.RoleRef.Kind, clusterRoleBinding.RoleRef.Name) || l.bindingExcluded(clusterRoleBinding.ObjectMeta) {
+			continue
+		}
+
 		for _, subject := range clusterRoleBinding.Subjects {
 			if l.nameMatches(subject.Name) && l.kindMatches(subject.Kind) {
 				subjectKey := subject.Name
 				if subject.Kind == "ServiceAccount" {
 					subjectKey = fmt.Sprintf("%s:%s", subject.Namespace, subject.Name)
 				}
+				if l.subjectExcluded(subjectKey) {
+					continue
+				}
 				if rbacSubj, exist := l.rbacSubjectsByScope[subjectKey]; exist {
 					rbacSubj.addClusterRoleBinding(&clusterRoleBinding)
 				} else {
@@ -168,32 +442,103 @@ func (l *lister) loadClusterRoleBindings() error {
 	return nil
 }
 
-func (l *lister) loadGkeIamPolicy(policy *cloudresourcemanager.Policy) {
+// loadGkeIamOnce loads the IAM policies and roles for the cluster's project
+// along with the Workload Identity links of its ServiceAccounts, unless they
+// already have been.
+func (l *lister) loadGkeIamOnce() error {
+	if l.gkePolicies != nil {
+		return nil
+	}
+
+	policies, roles, iamService, err := loadGkeIAMPolicies(l.gkeParsedProjectName)
+	if err != nil {
+		return err
+	}
+
+	l.gkePolicies, l.gkeRoles = policies, roles
+	return l.loadWorkloadIdentities(iamService)
+}
+
+// grantedGkeRole returns the role an IAM binding grants, recording the level it
+// was set at and its condition, if it grants cluster access and matches the
+// role filter.
+func (l *lister) grantedGkeRole(policy gkeIamPolicy, binding *cloudresourcemanager.Binding) (simpleRole, bool) {
+	sr, ok := l.gkeRoles[binding.Role]
+	if !ok {
+		sr, ok = gkeIamRoles[binding.Role]
+	}
+
+	if !ok || !l.roleMatches(sr.Kind, sr.Name) {
+		return simpleRole{}, false
+	}
+
+	sr.Source.Level = policy.Level
+	if !l.showRules {
+		sr.Rules = nil
+	}
+
+	if binding.Condition != nil {
+		sr.Condition = &iamCondition{
+			Title:       binding.Condition.Title,
+			Description: binding.Condition.Description,
+			Expression:  binding.Condition.Expression,
+		}
+	}
+
+	return sr, true
+}
+
+// loadGkeIamPolicy adds the GKE and GCP roles granted by an IAM policy,
+// recording the level it was set at in each role's source.
+func (l *lister) loadGkeIamPolicy(policy gkeIamPolicy) {
 	for _, binding := range policy.Bindings {
-		if sr, ok := gkeIamRoles[binding.Role]; ok {
-			for _, member := range binding.Members {
-				s := strings.Split(member, ":")
-				memberKind := strings.Title(s[0])
-				memberName := s[1]
-				if l.nameMatches(memberName) && l.kindMatches(memberKind) {
-					rbacSubj, exist := l.rbacSubjectsByScope[memberName]
+		if sr, ok := l.grantedGkeRole(policy, binding); ok {
+			for _, m := range binding.Members {
+				member, ok := parseIamMember(m)
+				if !ok {
+					continue
+				}
+
+				if l.nameMatches(member.Name) && l.kindMatches(member.Kind) && !l.subjectExcluded(member.Name) {
+					rbacSubj, exist := l.rbacSubjectsByScope[member.Name]
 					if !exist {
 						rbacSubj = rbacSubject{
-							Kind:         memberKind,
+							Kind:         member.Kind,
 							RolesByScope: make(map[string][]simpleRole),
 						}
 					}
 
+					// A deleted principal's grants no longer apply to anyone
+					if member.Deleted {
+						rbacSubj.Missing = member.Kind
+					}
+
 					rbacSubj.RolesByScope[gkeIamScope] = append(rbacSubj.RolesByScope[gkeIamScope], sr)
-					l.rbacSubjectsByScope[memberName] = rbacSubj
+					l.rbacSubjectsByScope[member.Name] = rbacSubj
 				}
 			}
 		}
 	}
 }
 
+// matcherQuery returns the subject query as given, or an empty string if
+// there is none.
+func (l *lister) matcherQuery() string {
+	if l.matcher == nil {
+		return ""
+	}
+	return l.matcher.rawQuery
+}
+
+// nameMatches reports whether a subject matches the query by name, or by
+// its display name for Azure AD object IDs.
 func (l *lister) nameMatches(name string) bool {
-	return l.filter == "" || strings.Contains(name, l.filter)
+	if l.matcher == nil || l.matcher.matches(name) {
+		return true
+	}
+
+	displayName := l.displayName(name)
+	return displayName != "" && l.matcher.matches(displayName)
 }
 
 func (l *lister) kindMatches(kind string) bool {
@@ -200,8 +200,53 @@ func TestLoadAll(t *testing.T) {
 	assert.EqualValues(t, expectedRbacSubjectSA, l.rbacSubjectsByScope["circleci:circleci"])
 }
 
+func TestLoadAllNamespaces(t *testing.T) {
+	l := genLister()
+	l.namespaceFilter = []string{"two", "three"}
+
+	createRoleBindings(t, l)
+	createClusterRoleBindings(t, l)
+
+	loadAll(t, l)
+
+	assert.Len(t, l.rbacSubjectsByScope, 3, "Expected 3 rbac subjects")
+	assert.EqualValues(t, map[string][]simpleRole{
+		"cluster-wide": {{
+			Kind: "ClusterRole",
+			Name: "bar",
+			Source: simpleRoleSource{
+				Kind: "ClusterRoleBinding",
+				Name: "testing",
+			},
+		}},
+	}, l.rbacSubjectsByScope["joe"].RolesByScope, "Expected only cluster-wide roles outside the namespaces")
+	assert.Len(t, l.rbacSubjectsByScope["circleci:circleci"].RolesByScope, 3, "Expected cluster-wide, two and three scopes")
+
+	l = genLister()
+	l.namespaceFilter = []string{"foo"}
+	l.namespacedOnly = true
+
+	createRoleBindings(t, l)
+	createClusterRoleBindings(t, l)
+
+	loadAll(t, l)
+
+	assert.Len(t, l.rbacSubjectsByScope, 2, "Expected 2 rbac subjects")
+	assert.EqualValues(t, map[string][]simpleRole{
+		"foo": {{
+			Kind: "Role",
+			Name: "bar",
+			Source: simpleRoleSource{
+				Kind: "RoleBinding",
+				Name: "testing",
+			},
+		}},
+	}, l.rbacSubjectsByScope["sue"].RolesByScope, "Expected only RoleBindings in foo")
+}
+
 func TestLoadGke(t *testing.T) {
-	policy := &cloudresourcemanager.Policy{
+	policy := gkeIamPolicy{
+		Level: "projects/example",
 		Bindings: []*cloudresourcemanager.Binding{{
 			Role:    "roles/container.admin",
 			Members: []string{"user:jane@example.com", "user:joe@example.com"},
@@ -232,15 +277,17 @@ func TestLoadGke(t *testing.T) {
 				Kind: "IAM",
 				Name: "gke-admin",
 				Source: simpleRoleSource{
-					Kind: "IAMRole",
-					Name: "container.admin",
+					Kind:  "IAMRole",
+					Name:  "container.admin",
+					Level: "projects/example",
 				},
 			}, {
 				Kind: "IAM",
 				Name: "gcp-owner",
 				Source: simpleRoleSource{
-					Kind: "IAMRole",
-					Name: "owner",
+					Kind:  "IAMRole",
+					Name:  "owner",
+					Level: "projects/example",
 				},
 			}},
 		},
@@ -253,8 +300,9 @@ func TestLoadGke(t *testing.T) {
 				Kind: "IAM",
 				Name: "gke-admin",
 				Source: simpleRoleSource{
-					Kind: "IAMRole",
-					Name: "container.admin",
+					Kind:  "IAMRole",
+					Name:  "container.admin",
+					Level: "projects/example",
 				},
 			}},
 		},
@@ -267,8 +315,9 @@ func TestLoadGke(t *testing.T) {
 				Kind: "IAM",
 				Name: "gcp-viewer",
 				Source: simpleRoleSource{
-					Kind: "IAMRole",
-					Name: "viewer",
+					Kind:  "IAMRole",
+					Name:  "viewer",
+					Level: "projects/example",
 				},
 			}},
 		},
@@ -281,8 +330,9 @@ func TestLoadGke(t *testing.T) {
 				Kind: "IAM",
 				Name: "gke-developer",
 				Source: simpleRoleSource{
-					Kind: "IAMRole",
-					Name: "container.developer",
+					Kind:  "IAMRole",
+					Name:  "container.developer",
+					Level: "projects/example",
 				},
 			}},
 		},
@@ -290,7 +340,8 @@ func TestLoadGke(t *testing.T) {
 }
 
 func TestLoadGkeFilters(t *testing.T) {
-	policy := &cloudresourcemanager.Policy{
+	policy := gkeIamPolicy{
+		Level: "projects/example",
 		Bindings: []*cloudresourcemanager.Binding{{
 			Role:    "roles/container.admin",
 			Members: []string{"user:jane@example.com", "user:joe@example.com"},
@@ -307,7 +358,7 @@ func TestLoadGkeFilters(t *testing.T) {
 	}
 
 	l := genLister()
-	l.filter = "example"
+	l.matcher = &nameMatcher{query: "example"}
 	l.subjectKind = "user"
 
 	assert.Len(t, l.rbacSubjectsByScope, 0, "Expected no rbac subjects initially")
@@ -323,15 +374,17 @@ func TestLoadGkeFilters(t *testing.T) {
 				Kind: "IAM",
 				Name: "gke-admin",
 				Source: simpleRoleSource{
-					Kind: "IAMRole",
-					Name: "container.admin",
+					Kind:  "IAMRole",
+					Name:  "container.admin",
+					Level: "projects/example",
 				},
 			}, {
 				Kind: "IAM",
 				Name: "gcp-owner",
 				Source: simpleRoleSource{
-					Kind: "IAMRole",
-					Name: "owner",
+					Kind:  "IAMRole",
+					Name:  "owner",
+					Level: "projects/example",
 				},
 			}},
 		},
@@ -344,8 +397,9 @@ func TestLoadGkeFilters(t *testing.T) {
 				Kind: "IAM",
 				Name: "gke-admin",
 				Source: simpleRoleSource{
-					Kind: "IAMRole",
-					Name: "container.admin",
+					Kind:  "IAMRole",
+					Name:  "container.admin",
+					Level: "projects/example",
 				},
 			}},
 		},
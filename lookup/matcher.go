@@ -0,0 +1,126 @@
+// Copyright 2018 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lookup
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var matchModes = []string{"contains", "exact", "prefix", "glob", "regex"}
+
+// ValidateMatchMode returns an error listing the supported match modes if
+// matchMode is not one of them. An empty mode means contains.
+func ValidateMatchMode(matchMode string) error {
+	if matchMode == "" {
+		return nil
+	}
+
+	for _, m := range matchModes {
+		if matchMode == m {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unsupported match mode %q, must be one of: %s", matchMode, strings.Join(matchModes, ", "))
+}
+
+// nameMatcher compares subject names against a query. An empty query
+// matches every name.
+type nameMatcher struct {
+	rawQuery   string
+	query      string
+	mode       string
+	ignoreCase bool
+	re         *regexp.Regexp
+}
+
+func newNameMatcher(query, mode string, ignoreCase bool) (*nameMatcher, error) {
+	if err := ValidateMatchMode(mode); err != nil {
+		return nil, err
+	}
+
+	m := nameMatcher{
+		rawQuery:   query,
+		query:      query,
+		mode:       mode,
+		ignoreCase: ignoreCase,
+	}
+
+	switch mode {
+	case "regex":
+		expr := query
+		if ignoreCase {
+			expr = "(?i)" + expr
+		}
+
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %q: %v", query, err)
+		}
+		m.re = re
+	case "glob":
+		if _, err := path.Match(query, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %v", query, err)
+		}
+	}
+
+	if ignoreCase {
+		m.query = strings.ToLower(query)
+	}
+
+	return &m, nil
+}
+
+func (m *nameMatcher) matches(name string) bool {
+	if m.query == "" {
+		return true
+	}
+
+	if m.re != nil {
+		return m.re.MatchString(name)
+	}
+
+	if m.ignoreCase {
+		name = strings.ToLower(name)
+	}
+
+	switch m.mode {
+	case "exact":
+		return name == m.query
+	case "prefix":
+		return strings.HasPrefix(name, m.query)
+	case "glob":
+		matched, _ := path.Match(m.query, name)
+		return matched
+	default:
+		return strings.Contains(name, m.query)
+	}
+}
+
+// isLiteral reports whether the query only matches the name it spells out,
+// rather than being a pattern for other names.
+func (m *nameMatcher) isLiteral() bool {
+	switch m.mode {
+	case "exact":
+		return true
+	case "glob":
+		return !strings.ContainsAny(m.rawQuery, `*?[\`)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2018 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lookup
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNameMatcher(t *testing.T) {
+	names := []string{"rob", "robert", "jrobinson", "Rob@example.com"}
+
+	cases := []struct {
+		query      string
+		mode       string
+		ignoreCase bool
+		expected   []string
+	}{
+		{"", "", false, names},
+		{"", "exact", false, names},
+		{"rob", "", false, []string{"rob", "robert", "jrobinson"}},
+		{"rob", "contains", true, names},
+		{"rob", "exact", false, []string{"rob"}},
+		{"ROB", "exact", true, []string{"rob"}},
+		{"rob", "prefix", false, []string{"rob", "robert"}},
+		{"rob", "prefix", true, []string{"rob", "robert", "Rob@example.com"}},
+		{"*@example.com", "glob", false, []string{"Rob@example.com"}},
+		{"rob?rt", "glob", false, []string{"robert"}},
+		{"^rob(ert)?$", "regex", false, []string{"rob", "robert"}},
+		{"^rob", "regex", true, []string{"rob", "robert", "Rob@example.com"}},
+	}
+
+	for _, c := range cases {
+		m, err := newNameMatcher(c.query, c.mode, c.ignoreCase)
+		assert.Nil(t, err, "Expected no error creating matcher")
+
+		matched := []string{}
+		for _, name := range names {
+			if m.matches(name) {
+				matched = append(matched, name)
+			}
+		}
+		assert.Equal(t, c.expected, matched, "Unexpected matches for %q with mode %q", c.query, c.mode)
+	}
+}
+
+func TestNameMatcherErrors(t *testing.T) {
+	_, err := newNameMatcher("rob", "fuzzy", false)
+	assert.EqualError(t, err, `unsupported match mode "fuzzy", must be one of: contains, exact, prefix, glob, regex`)
+
+	_, err = newNameMatcher("rob(", "regex", false)
+	assert.NotNil(t, err, "Expected an error for an invalid regex")
+
+	_, err = newNameMatcher("rob[", "glob", false)
+	assert.NotNil(t, err, "Expected an error for an invalid glob")
+}
+
+func TestLoadRoleBindingsMatchExact(t *testing.T) {
+	l := genLister()
+	l.matcher = &nameMatcher{query: "circle", mode: "exact"}
+
+	createRoleBindings(t, l)
+	loadRoleBindings(t, l)
+
+	assert.Len(t, l.rbacSubjectsByScope, 0, "Expected no rbac subjects for partial exact match")
+
+	l.matcher = &nameMatcher{query: "circleci", mode: "exact"}
+	loadRoleBindings(t, l)
+
+	assert.Len(t, l.rbacSubjectsByScope, 1, "Expected 1 rbac subject")
+}
+
+func TestNameMatcherIsLiteral(t *testing.T) {
+	cases := []struct {
+		query    string
+		mode     string
+		expected bool
+	}{
+		{"jane@example.com", "exact", true},
+		{"jane@example.com", "glob", true},
+		{"*@example.com", "glob", false},
+		{"jane@example.com", "regex", false},
+		{"jane@example.com", "prefix", false},
+		{"jane@example.com", "", false},
+	}
+
+	for _, c := range cases {
+		m, err := newNameMatcher(c.query, c.mode, false)
+		assert.NoError(t, err)
+		assert.Equal(t, c.expected, m.isLiteral(), "Unexpected result for %q with mode %q", c.query, c.mode)
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2018 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lookup
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// OpenShift keeps group membership in its own API rather than leaving it
+// to an identity provider. Groups list their users, while the groups field
+// on Users is deprecated but still honored.
+var (
+	openshiftGroupsResource = schema.GroupVersionResource{Group: "user.openshift.io", Version: "v1", Resource: "groups"}
+	openshiftUsersResource  = schema.GroupVersionResource{Group: "user.openshift.io", Version: "v1", Resource: "users"}
+)
+
+// loadOpenShiftGroups adds the membership of OpenShift Groups and Users to
+// the group map when the cluster serves the user.openshift.io API.
+func (l *lister) loadOpenShiftGroups() error {
+	if l.dynamicClient == nil || l.openshiftLoaded {
+		return nil
+	}
+	l.openshiftLoaded = true
+
+	served, err := l.openshiftResources()
+	if err != nil {
+		fmt.Println("Error discovering OpenShift user APIs")
+		return err
+	}
+
+	if served["groups"] {
+		if err := l.loadOpenShiftMembership(openshiftGroupsResource, "users", func(group, user string) {
+			l.addGroupMember(user, group)
+		}); err != nil {
+			return err
+		}
+	}
+
+	if served["users"] {
+		if err := l.loadOpenShiftMembership(openshiftUsersResource, "groups", func(user, group string) {
+			l.addGroupMember(user, group)
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// openshiftResources returns the user.openshift.io resources the cluster
+// serves, which is none on anything but OpenShift.
+func (l *lister) openshiftResources() (map[string]bool, error) {
+	served := make(map[string]bool)
+
+	resources, err := l.clientset.Discovery().ServerResourcesForGroupVersion(openshiftGroupsResource.GroupVersion().String())
+	if apierrors.IsNotFound(err) {
+		return served, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, resource := range resources.APIResources {
+		served[resource.Name] = true
+	}
+
+	return served, nil
+}
+
+// loadOpenShiftMembership lists every object of resource and calls add with
+// its name and each entry of its field list. Not being allowed to list them
+// only means membership cannot be expanded, so it is reported but ignored.
+func (l *lister) loadOpenShiftMembership(resource schema.GroupVersionResource, field string, add func(name, member string)) error {
+	list, err := l.dynamicClient.Resource(resource).List(context.Background(), metav1.ListOptions{})
+
+	if apierrors.IsForbidden(err) {
+		fmt.Fprintf(os.Stderr, "Not allowed to list OpenShift %s, their membership will not be included\n", resource.Resource)
+		return nil
+	}
+	if err != nil {
+		fmt.Printf("Error loading OpenShift %s\n", resource.Resource)
+		return err
+	}
+
+	for _, item := range list.Items {
+		// OpenShift serializes empty membership as null rather than []
+		value, _, _ := unstructured.NestedFieldNoCopy(item.Object, field)
+
+		switch members := value.(type) {
+		case nil:
+			continue
+		case []interface{}:
+			for _, member := range members {
+				name, ok := member.(string)
+				if !ok {
+					return fmt.Errorf("invalid %s in %s %s: %v is not a string", field, resource.Resource, item.GetName(), member)
+				}
+				add(item.GetName(), name)
+			}
+		default:
+			return fmt.Errorf("invalid %s in %s %s: %v is not a list", field, resource.Resource, item.GetName(), value)
+		}
+	}
+
+	return nil
+}
+
+func (l *lister) addGroupMember(user, group string) {
+	if l.groupMap == nil {
+		l.groupMap = make(map[string][]string)
+	}
+
+	if !contains(l.groupMap[user], group) {
+		l.groupMap[user] = append(l.groupMap[user], group)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2018 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lookup
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestOpenShiftGroups(t *testing.T) {
+	l := genLister()
+	l.matcher = &nameMatcher{query: "alice"}
+	l.dynamicClient = genOpenShiftClient(
+		openShiftObject("Group", "sre", "users", "alice", "bob"),
+		openShiftObject("User", "alice", "groups", "legacy-admins"),
+		openShiftObject("User", "carol", "groups"),
+		openShiftObject("Group", "empty", "users"),
+	)
+
+	l.clientset.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{{
+		GroupVersion: "user.openshift.io/v1",
+		APIResources: []metav1.APIResource{{Name: "groups"}, {Name: "users"}},
+	}}
+
+	createRoleBindings(t, l)
+
+	clusterRoleBindings := []rbacv1.ClusterRoleBinding{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "sre-view",
+		},
+		Subjects: []rbacv1.Subject{{
+			Name: "sre",
+			Kind: "Group",
+		}},
+		RoleRef: rbacv1.RoleRef{
+			Kind: "ClusterRole",
+			Name: "view",
+		},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "legacy-admins",
+		},
+		Subjects: []rbacv1.Subject{{
+			Name: "legacy-admins",
+			Kind: "Group",
+		}},
+		RoleRef: rbacv1.RoleRef{
+			Kind: "ClusterRole",
+			Name: "admin",
+		},
+	}}
+
+	for _, clusterRoleBinding := range clusterRoleBindings {
+		_, err := l.clientset.RbacV1().ClusterRoleBindings().Create(context.Background(), &clusterRoleBinding, metav1.CreateOptions{})
+		assert.Nil(t, err, "Expected no error creating cluster role bindings")
+	}
+
+	assert.Nil(t, l.loadAll(), "Expected no error loading all rbac Bindings")
+
+	assert.Equal(t, map[string][]string{
+		"alice": {"sre", "legacy-admins"},
+		"bob":   {"sre"},
+	}, l.groupMap)
+
+	var buf bytes.Buffer
+	err := l.printRbacBindings(&buf, "wide")
+	assert.Nil(t, err, "Expected no error printing")
+	assert.Equal(t, `SUBJECT       SCOPE          ROLE                SOURCE
+User/alice    cluster-wide   ClusterRole/admin   ClusterRoleBinding/legacy-admins (via legacy-admins)
+User/alice    cluster-wide   ClusterRole/view    ClusterRoleBinding/sre-view (via sre)
+`, buf.String())
+}
+
+func TestOpenShiftGroupsNotServed(t *testing.T) {
+	l := genLister()
+	l.dynamicClient = genOpenShiftClient(openShiftObject("Group", "sre", "users", "alice"))
+
+	createRoleBindings(t, l)
+
+	assert.Nil(t, l.loadAll(), "Expected no error loading all rbac Bindings")
+	assert.Nil(t, l.groupMap, "Expected groups to be ignored when the API is not served")
+}
+
+func genOpenShiftClient(objects ...runtime.Object) *dynamicfake.FakeDynamicClient {
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		openshiftGroupsResource: "GroupList",
+		openshiftUsersResource:  "UserList",
+	}, objects...)
+}
+
+// openShiftObject returns a Group or User with the given members, or with
+// null membership like OpenShift serializes it if there are none.
+func openShiftObject(kind, name, field string, members ...string) *unstructured.Unstructured {
+	var values interface{}
+	if len(members) > 0 {
+		list := make([]interface{}, 0, len(members))
+		for _, member := range members {
+			list = append(list, member)
+		}
+		values = list
+	}
+
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "user.openshift.io/v1",
+		"kind":       kind,
+		"metadata": map[string]interface{}{
+			"name": name,
+		},
+		field: values,
+	}}
+}
@@ -0,0 +1,119 @@
+// Copyright 2018 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lookup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func (l *lister) loadServiceAccounts() error {
+	if err := l.listServiceAccounts(); err != nil {
+		fmt.Println("Error loading service accounts")
+		return err
+	}
+
+	return nil
+}
+
+// loadServiceAccountsOnce loads ServiceAccounts unless they already have
+// been, for features that only add to the results. If listing them is
+// forbidden, it warns that the feature is skipped and leaves them empty.
+func (l *lister) loadServiceAccountsOnce(feature string) error {
+	if l.serviceAccounts != nil {
+		return nil
+	}
+
+	err := l.listServiceAccounts()
+	if apierrors.IsForbidden(err) {
+		fmt.Fprintf(os.Stderr, "Not allowed to list ServiceAccounts, skipping %s\n", feature)
+		l.serviceAccounts = make(map[string]corev1.ServiceAccount)
+		return nil
+	}
+	if err != nil {
+		fmt.Println("Error loading service accounts")
+		return err
+	}
+
+	return nil
+}
+
+func (l *lister) listServiceAccounts() error {
+	serviceAccounts, err := l.clientset.CoreV1().ServiceAccounts("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	l.serviceAccounts = make(map[string]corev1.ServiceAccount, len(serviceAccounts.Items))
+	for _, serviceAccount := range serviceAccounts.Items {
+		l.serviceAccounts[fmt.Sprintf("%s:%s", serviceAccount.Namespace, serviceAccount.Name)] = serviceAccount
+	}
+
+	return nil
+}
+
+func (l *lister) loadNamespaces() error {
+	namespaces, err := l.clientset.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{})
+
+	if err != nil {
+		fmt.Println("Error loading namespaces")
+		return err
+	}
+
+	l.namespaces = make(map[string]bool, len(namespaces.Items))
+	for _, namespace := range namespaces.Items {
+		l.namespaces[namespace.Name] = true
+	}
+
+	return nil
+}
+
+// filterOrphans keeps only the ServiceAccount subjects whose namespace or
+// ServiceAccount no longer exists, recording which one is missing. Anyone
+// who recreates them inherits every grant listed.
+func (l *lister) filterOrphans() {
+	for subjectKey, rbacSubj := range l.rbacSubjectsByScope {
+		// Google service accounts from --gke are ServiceAccounts too, but
+		// aren't named namespace:name
+		namespace, _, isKubernetes := strings.Cut(subjectKey, ":")
+		if rbacSubj.Kind != "ServiceAccount" || !isKubernetes {
+			delete(l.rbacSubjectsByScope, subjectKey)
+			continue
+		}
+
+		switch {
+		case !l.namespaces[namespace]:
+			rbacSubj.Missing = "Namespace"
+		case !l.serviceAccountExists(subjectKey):
+			rbacSubj.Missing = "ServiceAccount"
+		default:
+			delete(l.rbacSubjectsByScope, subjectKey)
+			continue
+		}
+
+		l.rbacSubjectsByScope[subjectKey] = rbacSubj
+	}
+}
+
+func (l *lister) serviceAccountExists(subjectKey string) bool {
+	_, ok := l.serviceAccounts[subjectKey]
+	return ok
+}
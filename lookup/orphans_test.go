@@ -0,0 +1,109 @@
+// Copyright 2018 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lookup
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/api/cloudresourcemanager/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestFilterOrphans(t *testing.T) {
+	l := genLister()
+	l.orphans = true
+
+	createRoleBindings(t, l)
+	createClusterRoleBindings(t, l)
+
+	extraBinding := rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "deployers",
+			Namespace: "two",
+		},
+		Subjects: []rbacv1.Subject{{
+			Name:      "deployer",
+			Kind:      "ServiceAccount",
+			Namespace: "two",
+		}, {
+			Name:      "old-deployer",
+			Kind:      "ServiceAccount",
+			Namespace: "two",
+		}},
+		RoleRef: rbacv1.RoleRef{
+			Kind: "ClusterRole",
+			Name: "edit",
+		},
+	}
+	_, err := l.clientset.RbacV1().RoleBindings("two").Create(context.Background(), &extraBinding, metav1.CreateOptions{})
+	assert.Nil(t, err, "Expected no error creating role bindings")
+
+	createServiceAccounts(t, l)
+
+	loadAll(t, l)
+	assert.Nil(t, l.loadServiceAccounts(), "Expected no error loading service accounts")
+	assert.Nil(t, l.loadNamespaces(), "Expected no error loading namespaces")
+
+	l.loadGkeIamPolicy(gkeIamPolicy{
+		Level: "projects/example",
+		Bindings: []*cloudresourcemanager.Binding{{
+			Role:    "roles/container.developer",
+			Members: []string{"serviceAccount:gsa@example.iam.gserviceaccount.com"},
+		}},
+	})
+
+	l.filterOrphans()
+
+	assert.Len(t, l.rbacSubjectsByScope, 2, "Expected 2 orphaned rbac subjects")
+	assert.Equal(t, "Namespace", l.rbacSubjectsByScope["circleci:circleci"].Missing)
+	assert.Equal(t, "ServiceAccount", l.rbacSubjectsByScope["two:old-deployer"].Missing)
+
+	var buf bytes.Buffer
+	err = l.printRbacBindings(&buf, "")
+	assert.Nil(t, err, "Expected no error printing")
+	assert.Equal(t, `SUBJECT              SCOPE          ROLE                        MISSING
+circleci:circleci    cluster-wide   ClusterRole/cluster-admin   Namespace
+circleci:circleci    three          ClusterRole/cluster-admin   Namespace
+circleci:circleci    two            ClusterRole/cluster-admin   Namespace
+two:old-deployer     two            ClusterRole/edit            ServiceAccount
+`, buf.String())
+}
+
+func createServiceAccounts(t *testing.T, l lister) {
+	for _, name := range []string{"two", "three", "foo"} {
+		namespace := corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+			},
+		}
+		_, err := l.clientset.CoreV1().Namespaces().Create(context.Background(), &namespace, metav1.CreateOptions{})
+		assert.Nil(t, err, "Expected no error creating namespaces")
+	}
+
+	serviceAccount := corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "deployer",
+			Namespace: "two",
+		},
+	}
+	_, err := l.clientset.CoreV1().ServiceAccounts("two").Create(context.Background(), &serviceAccount, metav1.CreateOptions{})
+	assert.Nil(t, err, "Expected no error creating service accounts")
+}
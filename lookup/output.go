@@ -0,0 +1,286 @@
+// Copyright 2018 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lookup
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// outputVersion is bumped whenever the structured output schema changes in
+// a way that could break consumers.
+const outputVersion = "v1"
+
+var outputFormats = []string{"normal", "wide", "json", "yaml", "csv", "tsv", "roles", "bindings"}
+
+// templateFormats take their template or spec after an equals sign, for
+// example jsonpath={.subjects[*].name}
+var templateFormats = []string{"go-template", "go-template-file", "jsonpath", "custom-columns"}
+
+// ValidateOutputFormat returns an error listing the supported formats if
+// outputFormat is not one of them. An empty format means normal output.
+func ValidateOutputFormat(outputFormat string) error {
+	if outputFormat == "" {
+		return nil
+	}
+
+	name, arg, hasArg := strings.Cut(outputFormat, "=")
+
+	if !hasArg {
+		for _, f := range outputFormats {
+			if name == f {
+				return nil
+			}
+		}
+	}
+
+	for _, f := range templateFormats {
+		if name == f {
+			if arg == "" {
+				return fmt.Errorf("output format %s requires a value, e.g. %s=...", name, name)
+			}
+			return nil
+		}
+	}
+
+	supported := append([]string{}, outputFormats...)
+	for _, f := range templateFormats {
+		supported = append(supported, f+"=...")
+	}
+
+	return fmt.Errorf("unsupported output format %q, must be one of: %s", outputFormat, strings.Join(supported, ", "))
+}
+
+type outputDocument struct {
+	Version  string          `json:"version"`
+	Subjects []outputSubject `json:"subjects"`
+}
+
+type outputSubject struct {
+	Name        string        `json:"name"`
+	DisplayName string        `json:"displayName,omitempty"`
+	Kind        string        `json:"kind"`
+	Missing     string        `json:"missing,omitempty"`
+	Scopes      []outputScope `json:"scopes"`
+}
+
+type outputScope struct {
+	Scope string       `json:"scope"`
+	Roles []simpleRole `json:"roles"`
+}
+
+// queryDocument is the structured output for multiple subject queries.
+type queryDocument struct {
+	Version string        `json:"version"`
+	Queries []queryResult `json:"queries"`
+}
+
+type queryResult struct {
+	Query    string          `json:"query"`
+	Subjects []outputSubject `json:"subjects"`
+}
+
+// outputRow is a single subject, scope and role combination, the unit
+// custom-columns are evaluated against.
+type outputRow struct {
+	Query              string     `json:"query,omitempty"`
+	Subject            string     `json:"subject"`
+	SubjectDisplayName string     `json:"subjectDisplayName,omitempty"`
+	SubjectKind        string     `json:"subjectKind"`
+	SubjectMissing     string     `json:"subjectMissing,omitempty"`
+	Scope              string     `json:"scope"`
+	Role               simpleRole `json:"role"`
+}
+
+func (l *lister) outputDocument() outputDocument {
+	doc := outputDocument{
+		Version:  outputVersion,
+		Subjects: []outputSubject{},
+	}
+
+	for _, subjectName := range l.subjectNames() {
+		rbacSubj := l.rbacSubjectsByScope[subjectName]
+		subject := outputSubject{
+			Name:        subjectName,
+			DisplayName: l.displayName(subjectName),
+			Kind:        rbacSubj.Kind,
+			Missing:     rbacSubj.Missing,
+			Scopes:      []outputScope{},
+		}
+
+		for _, scope := range sortedScopes(rbacSubj.RolesByScope) {
+			subject.Scopes = append(subject.Scopes, outputScope{
+				Scope: scope,
+				Roles: sortedRoles(rbacSubj.RolesByScope[scope]),
+			})
+		}
+
+		doc.Subjects = append(doc.Subjects, subject)
+	}
+
+	return doc
+}
+
+func (doc outputDocument) rows() []outputRow {
+	rows := []outputRow{}
+	for _, subject := range doc.Subjects {
+		for _, scope := range subject.Scopes {
+			for _, role := range scope.Roles {
+				rows = append(rows, outputRow{
+					Subject:            subject.Name,
+					SubjectDisplayName: subject.DisplayName,
+					SubjectKind:        subject.Kind,
+					SubjectMissing:     subject.Missing,
+					Scope:              scope.Scope,
+					Role:               role,
+				})
+			}
+		}
+	}
+	return rows
+}
+
+// subjectName is the subject as shown in normal output, using its display
+// name if it has one.
+func (row outputRow) subjectName() string {
+	if row.SubjectDisplayName != "" {
+		return row.SubjectDisplayName
+	}
+	return row.Subject
+}
+
+// qualifiedSubject is the subject along with its kind as shown in wide
+// output, keeping the original name next to any display name.
+func (row outputRow) qualifiedSubject() string {
+	if row.SubjectDisplayName != "" {
+		return fmt.Sprintf("%s/%s (%s)", row.SubjectKind, row.SubjectDisplayName, row.Subject)
+	}
+	return fmt.Sprintf("%s/%s", row.SubjectKind, row.Subject)
+}
+
+// outputRows returns every row of the output document ordered by the
+// lister's sort key.
+func (l *lister) outputRows() []outputRow {
+	rows := l.outputDocument().rows()
+	sortRows(rows, l.sortBy)
+	return rows
+}
+
+func printJSON(w io.Writer, v interface{}) error {
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(out, '\n'))
+	return err
+}
+
+func printYAML(w io.Writer, v interface{}) error {
+	out, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(out)
+	return err
+}
+
+// printDelimited writes one record per output row, comma separated for csv
+// and tab separated for tsv, quoting fields where needed. Rows for multiple
+// queries start with the query they matched.
+func printDelimited(w io.Writer, comma rune, rows []outputRow, withQuery bool) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+
+	header := []string{"SUBJECT", "SUBJECT KIND", "SCOPE", "ROLE KIND", "ROLE NAME", "SOURCE KIND", "SOURCE NAME", "SOURCE LEVEL", "VIA", "CONDITION"}
+	if withQuery {
+		header = append([]string{"QUERY"}, header...)
+	}
+
+	records := [][]string{header}
+	for _, row := range rows {
+		condition := ""
+		if row.Role.Condition != nil {
+			condition = row.Role.Condition.String()
+		}
+
+		record := []string{row.Subject, row.SubjectKind, row.Scope, row.Role.Kind, row.Role.Name, row.Role.Source.Kind, row.Role.Source.Name, row.Role.Source.Level, row.Role.Source.Via, condition}
+		if withQuery {
+			record = append([]string{row.Query}, record...)
+		}
+		records = append(records, record)
+	}
+
+	return cw.WriteAll(records)
+}
+
+// printQueryResults prints the results of several subject queries, each
+// lister holding the results of one query. Tables are printed one after
+// another, while structured formats group subjects under their query.
+func printQueryResults(w io.Writer, outputFormat string, listers []lister) error {
+	format, arg, _ := strings.Cut(outputFormat, "=")
+
+	doc := queryDocument{
+		Version: outputVersion,
+		Queries: []queryResult{},
+	}
+	rows := []outputRow{}
+
+	for _, l := range listers {
+		doc.Queries = append(doc.Queries, queryResult{
+			Query:    l.query,
+			Subjects: l.outputDocument().Subjects,
+		})
+
+		for _, row := range l.outputRows() {
+			row.Query = l.query
+			rows = append(rows, row)
+		}
+	}
+
+	switch format {
+	case "json":
+		return printJSON(w, doc)
+	case "yaml":
+		return printYAML(w, doc)
+	case "csv":
+		return printDelimited(w, ',', rows, true)
+	case "tsv":
+		return printDelimited(w, '\t', rows, true)
+	case "go-template", "go-template-file", "jsonpath":
+		return printTemplate(w, format, arg, doc)
+	case "custom-columns":
+		return printCustomColumns(w, arg, rows)
+	}
+
+	for i, l := range listers {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+
+		fmt.Fprintf(w, "QUERY: %s\n", l.query)
+		if err := l.printTable(w, outputFormat); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,238 @@
+// Copyright 2018 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lookup
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/yaml"
+)
+
+func TestPrintJSON(t *testing.T) {
+	l := genLister()
+
+	createRoleBindings(t, l)
+	createClusterRoleBindings(t, l)
+	loadAll(t, l)
+
+	var buf bytes.Buffer
+	err := l.printRbacBindings(&buf, "json")
+	assert.Nil(t, err, "Expected no error printing json")
+
+	var doc outputDocument
+	err = json.Unmarshal(buf.Bytes(), &doc)
+	assert.Nil(t, err, "Expected valid json output")
+
+	assert.Equal(t, "v1", doc.Version)
+	assert.Len(t, doc.Subjects, 3, "Expected 3 subjects")
+
+	assert.EqualValues(t, outputSubject{
+		Name: "circleci:circleci",
+		Kind: "ServiceAccount",
+		Scopes: []outputScope{{
+			Scope: "cluster-wide",
+			Roles: []simpleRole{{
+				Kind: "ClusterRole",
+				Name: "cluster-admin",
+				Source: simpleRoleSource{
+					Kind: "ClusterRoleBinding",
+					Name: "circleci-cluster-admin",
+				},
+			}},
+		}, {
+			Scope: "three",
+			Roles: []simpleRole{{
+				Kind: "ClusterRole",
+				Name: "cluster-admin",
+				Source: simpleRoleSource{
+					Kind: "RoleBinding",
+					Name: "testing-sa",
+				},
+			}},
+		}, {
+			Scope: "two",
+			Roles: []simpleRole{{
+				Kind: "ClusterRole",
+				Name: "cluster-admin",
+				Source: simpleRoleSource{
+					Kind: "RoleBinding",
+					Name: "testing-sa",
+				},
+			}},
+		}},
+	}, doc.Subjects[0])
+	assert.Equal(t, "joe", doc.Subjects[1].Name)
+	assert.Equal(t, "sue", doc.Subjects[2].Name)
+}
+
+func TestPrintJSONEmpty(t *testing.T) {
+	l := genLister()
+
+	var buf bytes.Buffer
+	err := l.printRbacBindings(&buf, "json")
+	assert.Nil(t, err, "Expected no error printing json")
+
+	assert.JSONEq(t, `{"version": "v1", "subjects": []}`, buf.String())
+}
+
+func TestPrintYAML(t *testing.T) {
+	l := genLister()
+
+	createClusterRoleBindings(t, l)
+	loadAll(t, l)
+
+	var jsonBuf, yamlBuf bytes.Buffer
+	assert.Nil(t, l.printRbacBindings(&jsonBuf, "json"), "Expected no error printing json")
+	assert.Nil(t, l.printRbacBindings(&yamlBuf, "yaml"), "Expected no error printing yaml")
+
+	converted, err := yaml.YAMLToJSON(yamlBuf.Bytes())
+	assert.Nil(t, err, "Expected valid yaml output")
+	assert.JSONEq(t, jsonBuf.String(), string(converted), "Expected yaml to mirror json output")
+}
+
+func TestValidateOutputFormat(t *testing.T) {
+	for _, f := range []string{"", "normal", "wide", "json", "yaml", "csv", "tsv"} {
+		assert.Nil(t, ValidateOutputFormat(f), "Expected %q to be a valid output format", f)
+	}
+
+	for _, f := range []string{"go-template={{.version}}", "go-template-file=out.tmpl", "jsonpath={.version}", "custom-columns=NAME:.subject"} {
+		assert.Nil(t, ValidateOutputFormat(f), "Expected %q to be a valid output format", f)
+	}
+
+	err := ValidateOutputFormat("xml")
+	assert.EqualError(t, err, `unsupported output format "xml", must be one of: normal, wide, json, yaml, csv, tsv, roles, bindings, go-template=..., go-template-file=..., jsonpath=..., custom-columns=...`)
+
+	err = ValidateOutputFormat("json=foo")
+	assert.NotNil(t, err, "Expected an error for json with a value")
+
+	err = ValidateOutputFormat("jsonpath=")
+	assert.EqualError(t, err, "output format jsonpath requires a value, e.g. jsonpath=...")
+}
+
+func TestPrintTemplates(t *testing.T) {
+	l := genLister()
+
+	createRoleBindings(t, l)
+	loadAll(t, l)
+
+	var buf bytes.Buffer
+	err := l.printRbacBindings(&buf, "go-template={{range .subjects}}{{.name}} {{end}}")
+	assert.Nil(t, err, "Expected no error printing go-template")
+	assert.Equal(t, "circleci:circleci joe sue ", buf.String())
+
+	buf.Reset()
+	err = l.printRbacBindings(&buf, `go-template={{if exists . "version"}}{{base64decode "djE="}}{{end}}`)
+	assert.Nil(t, err, "Expected kubectl's template functions to be available")
+	assert.Equal(t, "v1", buf.String())
+
+	tmplFile := filepath.Join(t.TempDir(), "output.tmpl")
+	err = os.WriteFile(tmplFile, []byte("{{.version}}"), 0600)
+	assert.Nil(t, err, "Expected no error writing template file")
+
+	buf.Reset()
+	err = l.printRbacBindings(&buf, "go-template-file="+tmplFile)
+	assert.Nil(t, err, "Expected no error printing go-template-file")
+	assert.Equal(t, "v1", buf.String())
+
+	buf.Reset()
+	err = l.printRbacBindings(&buf, `jsonpath={.subjects[?(@.kind=="User")].name}`)
+	assert.Nil(t, err, "Expected no error printing jsonpath")
+	assert.Equal(t, "joe sue", buf.String())
+
+	buf.Reset()
+	err = l.printRbacBindings(&buf, "jsonpath={.subjects[")
+	assert.NotNil(t, err, "Expected an error parsing invalid jsonpath")
+}
+
+func TestPrintCustomColumns(t *testing.T) {
+	l := genLister()
+
+	createRoleBindings(t, l)
+	loadAll(t, l)
+
+	var buf bytes.Buffer
+	err := l.printRbacBindings(&buf, "custom-columns=NAME:.subject,NAMESPACE:{.scope},BINDING:role.source.name,MISSING:.nope")
+	assert.Nil(t, err, "Expected no error printing custom-columns")
+	assert.Equal(t, `NAME                NAMESPACE   BINDING      MISSING
+circleci:circleci   three       testing-sa   <none>
+circleci:circleci   two         testing-sa   <none>
+joe                 foo         testing      <none>
+sue                 foo         testing      <none>
+`, buf.String())
+
+	err = l.printRbacBindings(&buf, "custom-columns=NAME")
+	assert.EqualError(t, err, "unexpected custom-columns spec: NAME, expected <header>:<json-path-expr>")
+}
+
+func TestPrintDelimited(t *testing.T) {
+	l := genLister()
+	l.rbacSubjectsByScope["jane, \"ops\""] = rbacSubject{
+		Kind: "User",
+		RolesByScope: map[string][]simpleRole{
+			"cluster-wide": {{
+				Kind: "ClusterRole",
+				Name: "view",
+				Source: simpleRoleSource{
+					Kind: "ClusterRoleBinding",
+					Name: "jane-view",
+				},
+			}},
+		},
+	}
+
+	var buf bytes.Buffer
+	err := l.printRbacBindings(&buf, "csv")
+	assert.Nil(t, err, "Expected no error printing csv")
+	assert.Equal(t, `SUBJECT,SUBJECT KIND,SCOPE,ROLE KIND,ROLE NAME,SOURCE KIND,SOURCE NAME,SOURCE LEVEL,VIA,CONDITION
+"jane, ""ops""",User,cluster-wide,ClusterRole,view,ClusterRoleBinding,jane-view,,,
+`, buf.String())
+
+	buf.Reset()
+	err = l.printRbacBindings(&buf, "tsv")
+	assert.Nil(t, err, "Expected no error printing tsv")
+	assert.Equal(t, "SUBJECT\tSUBJECT KIND\tSCOPE\tROLE KIND\tROLE NAME\tSOURCE KIND\tSOURCE NAME\tSOURCE LEVEL\tVIA\tCONDITION\n"+
+		"\"jane, \"\"ops\"\"\"\tUser\tcluster-wide\tClusterRole\tview\tClusterRoleBinding\tjane-view\t\t\t\n", buf.String())
+
+	l.rbacSubjectsByScope = map[string]rbacSubject{
+		"rob@example.com": {
+			Kind: "User",
+			RolesByScope: map[string][]simpleRole{
+				gkeIamScope: {{
+					Kind: "IAM",
+					Name: "gke-admin",
+					Source: simpleRoleSource{
+						Kind:  "IAMRole",
+						Name:  "container.admin",
+						Via:   "ops@example.com",
+						Level: "folders/1234",
+					},
+					Condition: &iamCondition{Title: "until friday", Expression: "request.time < timestamp('2026-10-23T00:00:00Z')"},
+				}},
+			},
+		},
+	}
+
+	buf.Reset()
+	err = l.printRbacBindings(&buf, "csv")
+	assert.Nil(t, err, "Expected no error printing csv")
+	assert.Equal(t, `SUBJECT,SUBJECT KIND,SCOPE,ROLE KIND,ROLE NAME,SOURCE KIND,SOURCE NAME,SOURCE LEVEL,VIA,CONDITION
+rob@example.com,User,project-wide,IAM,gke-admin,IAMRole,container.admin,folders/1234,ops@example.com,until friday
+`, buf.String())
+}
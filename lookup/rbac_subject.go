@@ -15,31 +15,77 @@
 package lookup
 
 import (
+	"fmt"
+
 	rbacv1 "k8s.io/api/rbac/v1"
 )
 
+var clusterScope = "cluster-wide"
+
 type rbacSubject struct {
 	Kind         string
 	RolesByScope map[string][]simpleRole
+	Missing      string
 }
 
 type simpleRole struct {
-	Kind   string
-	Name   string
-	Source simpleRoleSource
+	Kind       string           `json:"kind"`
+	Name       string           `json:"name"`
+	Source     simpleRoleSource `json:"source"`
+	Aggregated bool             `json:"aggregated,omitempty"`
+	Missing    bool             `json:"missing,omitempty"`
+	Rules      []roleRule       `json:"rules,omitempty"`
+	Condition  *iamCondition    `json:"condition,omitempty"`
+}
+
+// conditionSuffix notes the IAM condition a role is granted under, if any,
+// so that conditional grants aren't mistaken for permanent ones.
+func (sr simpleRole) conditionSuffix() string {
+	if sr.Condition == nil {
+		return ""
+	}
+	return fmt.Sprintf(" (condition: %s)", sr.Condition)
+}
+
+// roleRule is a PolicyRule granted by a role. For aggregated ClusterRoles it
+// also records the component ClusterRole the rule was aggregated from.
+type roleRule struct {
+	rbacv1.PolicyRule
+	AggregatedFrom string `json:"aggregatedFrom,omitempty"`
+	// CustomResources limits the rule to custom resources, as GKE grants for
+	// thirdPartyObjects, since RBAC has no way to name them all.
+	CustomResources bool `json:"customResources,omitempty"`
 }
 
 type simpleRoleSource struct {
-	Kind string
-	Name string
+	Kind   string            `json:"kind"`
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Via    string            `json:"via,omitempty"`
+	// Level is the GCP resource an IAM role is granted on, e.g. folders/1234
+	Level string `json:"level,omitempty"`
+}
+
+// String formats the source as Kind/Name, noting the GCP resource it was
+// granted on and the group it was granted through if any.
+func (src simpleRoleSource) String() string {
+	s := fmt.Sprintf("%s/%s", src.Kind, src.Name)
+	if src.Level != "" {
+		s += fmt.Sprintf(" on %s", src.Level)
+	}
+	if src.Via != "" {
+		s += fmt.Sprintf(" (via %s)", src.Via)
+	}
+	return s
 }
 
 func (rbacSubj *rbacSubject) addRoleBinding(roleBinding *rbacv1.RoleBinding) {
 	simpleRole := simpleRole{
 		Name: roleBinding.RoleRef.Name,
 		Source: simpleRoleSource{
-			Name: roleBinding.Name,
-			Kind: "RoleBinding",
+			Name:   roleBinding.Name,
+			Kind:   "RoleBinding",
+			Labels: roleBinding.Labels,
 		},
 	}
 
@@ -50,10 +96,9 @@ func (rbacSubj *rbacSubject) addRoleBinding(roleBinding *rbacv1.RoleBinding) {
 func (rbacSubj *rbacSubject) addClusterRoleBinding(clusterRoleBinding *rbacv1.ClusterRoleBinding) {
 	simpleRole := simpleRole{
 		Name:   clusterRoleBinding.RoleRef.Name,
-		Source: simpleRoleSource{Name: clusterRoleBinding.Name, Kind: "ClusterRoleBinding"},
+		Source: simpleRoleSource{Name: clusterRoleBinding.Name, Kind: "ClusterRoleBinding", Labels: clusterRoleBinding.Labels},
 	}
 
 	simpleRole.Kind = clusterRoleBinding.RoleRef.Kind
-	scope := "cluster-wide"
-	rbacSubj.RolesByScope[scope] = append(rbacSubj.RolesByScope[scope], simpleRole)
+	rbacSubj.RolesByScope[clusterScope] = append(rbacSubj.RolesByScope[clusterScope], simpleRole)
 }
@@ -0,0 +1,101 @@
+// Copyright 2018 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lookup
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// roleMatcher selects bindings by the role they reference, given as
+// [KIND/]NAME where NAME may be a glob. Kinds are compared case
+// insensitively and an empty kind matches any kind of role.
+type roleMatcher struct {
+	kind string
+	name string
+}
+
+func newRoleMatcher(spec string) (*roleMatcher, error) {
+	m := roleMatcher{name: spec}
+	if kind, name, found := strings.Cut(spec, "/"); found {
+		m.kind = strings.ToLower(kind)
+		m.name = name
+	}
+
+	if m.name == "" {
+		return nil, fmt.Errorf("invalid role %q, must be [KIND/]NAME", spec)
+	}
+
+	if _, err := path.Match(m.name, ""); err != nil {
+		return nil, fmt.Errorf("invalid role %q: %v", spec, err)
+	}
+
+	return &m, nil
+}
+
+func (m *roleMatcher) matches(kind, name string) bool {
+	if m.kind != "" && m.kind != strings.ToLower(kind) {
+		return false
+	}
+
+	matched, _ := path.Match(m.name, name)
+	return matched
+}
+
+func (l *lister) roleMatches(kind, name string) bool {
+	return l.roleFilter == nil || l.roleFilter.matches(kind, name)
+}
+
+// printRoleTable prints the role-centric view, listing every subject and
+// scope each role is bound to.
+func (l *lister) printRoleTable(w io.Writer) error {
+	rows := l.outputDocument().rows()
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i].Role, rows[j].Role
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Kind < b.Kind
+	})
+
+	tw := new(tabwriter.Writer)
+	tw.Init(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "ROLE\t SUBJECT\t SCOPE\t SOURCE")
+
+	previous := ""
+	for _, row := range rows {
+		role := fmt.Sprintf("%s/%s", row.Role.Kind, row.Role.Name)
+
+		// Each role is only named on the first of its rows
+		cell := role
+		if role == previous {
+			cell = ""
+		}
+		previous = role
+
+		if l.checkRefs && row.Role.Missing && cell != "" {
+			cell += " (missing)"
+		}
+
+		fmt.Fprintf(tw, "%s \t %s\t %s\t %s%s\n", cell, row.qualifiedSubject(), row.Scope, row.Role.Source, row.Role.conditionSuffix())
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,75 @@
+// Copyright 2018 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lookup
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoleMatcher(t *testing.T) {
+	cases := []struct {
+		spec     string
+		kind     string
+		name     string
+		expected bool
+	}{
+		{"cluster-admin", "ClusterRole", "cluster-admin", true},
+		{"cluster-admin", "Role", "cluster-admin", true},
+		{"clusterrole/cluster-admin", "ClusterRole", "cluster-admin", true},
+		{"Role/cluster-admin", "ClusterRole", "cluster-admin", false},
+		{"ClusterRole/system:*", "ClusterRole", "system:node", true},
+		{"ClusterRole/system:*", "ClusterRole", "cluster-admin", false},
+		{"*", "Role", "bar", true},
+	}
+
+	for _, c := range cases {
+		m, err := newRoleMatcher(c.spec)
+		assert.Nil(t, err, "Expected no error creating role matcher")
+		assert.Equal(t, c.expected, m.matches(c.kind, c.name), "Unexpected result for %q with %s/%s", c.spec, c.kind, c.name)
+	}
+
+	_, err := newRoleMatcher("ClusterRole/")
+	assert.EqualError(t, err, `invalid role "ClusterRole/", must be [KIND/]NAME`)
+
+	_, err = newRoleMatcher("admin[")
+	assert.NotNil(t, err, "Expected an error for an invalid glob")
+}
+
+func TestPrintRoleTable(t *testing.T) {
+	l := genLister()
+
+	createRoleBindings(t, l)
+	createClusterRoleBindings(t, l)
+
+	l.roleFilter = &roleMatcher{kind: "clusterrole", name: "*"}
+	loadAll(t, l)
+
+	assert.Len(t, l.rbacSubjectsByScope, 3, "Expected 3 rbac subjects")
+	assert.Len(t, l.rbacSubjectsByScope["joe"].RolesByScope, 1, "Expected only the ClusterRole binding for joe")
+
+	var buf bytes.Buffer
+	err := l.printRbacBindings(&buf, "roles")
+	assert.Nil(t, err, "Expected no error printing")
+	assert.Equal(t, `ROLE                         SUBJECT                            SCOPE          SOURCE
+ClusterRole/bar              User/joe                           cluster-wide   ClusterRoleBinding/testing
+                             User/sue                           cluster-wide   ClusterRoleBinding/testing
+ClusterRole/cluster-admin    ServiceAccount/circleci:circleci   cluster-wide   ClusterRoleBinding/circleci-cluster-admin
+                             ServiceAccount/circleci:circleci   three          RoleBinding/testing-sa
+                             ServiceAccount/circleci:circleci   two            RoleBinding/testing-sa
+`, buf.String())
+}
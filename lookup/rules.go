@@ -0,0 +1,242 @@
+// Copyright 2018 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lookup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func (l *lister) loadRoles() error {
+	l.roles = make(map[string]rbacv1.Role)
+
+	for _, namespace := range l.scopedNamespaces() {
+		roles, err := l.clientset.RbacV1().Roles(namespace).List(context.Background(), metav1.ListOptions{})
+
+		if err != nil {
+			fmt.Println("Error loading roles")
+			return err
+		}
+
+		for _, role := range roles.Items {
+			l.roles[roleKey(role.Namespace, role.Name)] = role
+		}
+	}
+
+	return nil
+}
+
+func (l *lister) loadClusterRoles() error {
+	clusterRoles, err := l.clientset.RbacV1().ClusterRoles().List(context.Background(), metav1.ListOptions{})
+
+	if err != nil {
+		fmt.Println("Error loading cluster roles")
+		return err
+	}
+
+	l.clusterRoles = make(map[string]rbacv1.ClusterRole, len(clusterRoles.Items))
+	for _, clusterRole := range clusterRoles.Items {
+		l.clusterRoles[clusterRole.Name] = clusterRole
+	}
+
+	return nil
+}
+
+// loadRolesOnce loads Roles and ClusterRoles unless they already have been.
+func (l *lister) loadRolesOnce() error {
+	if l.roles == nil {
+		if err := l.loadRoles(); err != nil {
+			return err
+		}
+	}
+
+	if l.clusterRoles == nil {
+		return l.loadClusterRoles()
+	}
+
+	return nil
+}
+
+// resolveRules fills in the PolicyRules of every Role and ClusterRole bound
+// to a loaded subject. Roles that could not be found are marked as missing.
+func (l *lister) resolveRules() {
+	for _, rbacSubj := range l.rbacSubjectsByScope {
+		for scope, simpleRoles := range rbacSubj.RolesByScope {
+			for i := range simpleRoles {
+				l.resolveRole(scope, &simpleRoles[i])
+			}
+		}
+	}
+}
+
+// resolveRole marks sr as missing if its role can't be found. Rules are only
+// filled in with --show-rules, --check-refs alone doesn't include them.
+func (l *lister) resolveRole(scope string, sr *simpleRole) {
+	switch sr.Kind {
+	case "Role":
+		role, ok := l.roles[roleKey(scope, sr.Name)]
+		if !ok {
+			sr.Missing = true
+			return
+		}
+		if l.showRules {
+			sr.Rules = toRoleRules(role.Rules, "")
+		}
+	case "ClusterRole":
+		clusterRole, ok := l.clusterRoles[sr.Name]
+		if !ok {
+			sr.Missing = true
+			return
+		}
+		if clusterRole.AggregationRule != nil {
+			sr.Aggregated = true
+		}
+		if !l.showRules {
+			return
+		}
+		if clusterRole.AggregationRule != nil {
+			sr.Rules = l.aggregatedRules(clusterRole)
+		} else {
+			sr.Rules = toRoleRules(clusterRole.Rules, "")
+		}
+	}
+}
+
+// refStatus describes whether the role referenced by a binding exists.
+// Only Roles and ClusterRoles are checked.
+func refStatus(sr simpleRole) string {
+	switch {
+	case sr.Missing:
+		return "missing"
+	case sr.Kind == "Role" || sr.Kind == "ClusterRole":
+		return "ok"
+	default:
+		return "n/a"
+	}
+}
+
+// aggregatedRules collects the rules of every ClusterRole selected by the
+// aggregation rule of clusterRole, noting which one each rule came from.
+// Like the aggregation controller, identical rules are only included once.
+func (l *lister) aggregatedRules(clusterRole rbacv1.ClusterRole) []roleRule {
+	names := make([]string, 0, len(l.clusterRoles))
+	for name := range l.clusterRoles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rules := []roleRule{}
+	for _, selector := range clusterRole.AggregationRule.ClusterRoleSelectors {
+		labelSelector, err := metav1.LabelSelectorAsSelector(&selector)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing aggregation rule for ClusterRole %s: %v\n", clusterRole.Name, err)
+			continue
+		}
+
+		for _, name := range names {
+			component := l.clusterRoles[name]
+			if name == clusterRole.Name || !labelSelector.Matches(labels.Set(component.Labels)) {
+				continue
+			}
+
+			for _, rule := range toRoleRules(component.Rules, "ClusterRole/"+name) {
+				if !containsRule(rules, rule.PolicyRule) {
+					rules = append(rules, rule)
+				}
+			}
+		}
+	}
+
+	return rules
+}
+
+func toRoleRules(policyRules []rbacv1.PolicyRule, aggregatedFrom string) []roleRule {
+	if policyRules == nil {
+		return nil
+	}
+
+	rules := make([]roleRule, 0, len(policyRules))
+	for _, policyRule := range policyRules {
+		rules = append(rules, roleRule{PolicyRule: policyRule, AggregatedFrom: aggregatedFrom})
+	}
+	return rules
+}
+
+func containsRule(rules []roleRule, policyRule rbacv1.PolicyRule) bool {
+	for _, rule := range rules {
+		if equality.Semantic.DeepEqual(rule.PolicyRule, policyRule) {
+			return true
+		}
+	}
+	return false
+}
+
+func roleKey(namespace, name string) string {
+	return fmt.Sprintf("%s/%s", namespace, name)
+}
+
+// formatRoleRule describes a rule on a single line along with the
+// ClusterRole it was aggregated from, if any.
+func formatRoleRule(rule roleRule) string {
+	formatted := formatPolicyRule(rule.PolicyRule)
+	if rule.CustomResources {
+		formatted += " (custom resources only)"
+	}
+
+	if rule.AggregatedFrom == "" {
+		return formatted
+	}
+	return fmt.Sprintf("%s (from %s)", formatted, rule.AggregatedFrom)
+}
+
+// formatPolicyRule describes a rule on a single line, leaving out empty
+// fields, e.g. verbs=[get list] apiGroups=[""] resources=[pods].
+func formatPolicyRule(rule rbacv1.PolicyRule) string {
+	apiGroups := make([]string, 0, len(rule.APIGroups))
+	for _, apiGroup := range rule.APIGroups {
+		if apiGroup == "" {
+			apiGroup = `""`
+		}
+		apiGroups = append(apiGroups, apiGroup)
+	}
+
+	fields := []struct {
+		name   string
+		values []string
+	}{
+		{"verbs", rule.Verbs},
+		{"apiGroups", apiGroups},
+		{"resources", rule.Resources},
+		{"resourceNames", rule.ResourceNames},
+		{"nonResourceURLs", rule.NonResourceURLs},
+	}
+
+	parts := []string{}
+	for _, f := range fields {
+		if len(f.values) > 0 {
+			parts = append(parts, fmt.Sprintf("%s=[%s]", f.name, strings.Join(f.values, " ")))
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
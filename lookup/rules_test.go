@@ -0,0 +1,266 @@
+// Copyright 2018 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lookup
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestShowRules(t *testing.T) {
+	l := genLister()
+	l.showRules = true
+
+	createRoleBindings(t, l)
+	createClusterRoleBindings(t, l)
+	createRoles(t, l)
+
+	loadAll(t, l)
+
+	assert.EqualValues(t, []simpleRole{{
+		Kind: "Role",
+		Name: "bar",
+		Source: simpleRoleSource{
+			Kind: "RoleBinding",
+			Name: "testing",
+		},
+		Rules: []roleRule{{
+			PolicyRule: rbacv1.PolicyRule{
+				Verbs:     []string{"get", "list"},
+				APIGroups: []string{""},
+				Resources: []string{"pods"},
+			},
+		}},
+	}}, l.rbacSubjectsByScope["joe"].RolesByScope["foo"])
+
+	assert.Len(t, l.rbacSubjectsByScope["circleci:circleci"].RolesByScope["cluster-wide"][0].Rules, 2)
+	assert.Nil(t, l.rbacSubjectsByScope["joe"].RolesByScope["cluster-wide"][0].Rules, "Expected no rules for missing ClusterRole")
+	assert.True(t, l.rbacSubjectsByScope["joe"].RolesByScope["cluster-wide"][0].Missing, "Expected ClusterRole/bar to be missing")
+
+	var buf bytes.Buffer
+	err := l.printRbacBindings(&buf, "")
+	assert.Nil(t, err, "Expected no error printing")
+	assert.Equal(t, `SUBJECT              SCOPE          ROLE                        RULES
+circleci:circleci    cluster-wide   ClusterRole/cluster-admin   verbs=[*] apiGroups=[*] resources=[*]
+                                                                verbs=[*] nonResourceURLs=[*]
+circleci:circleci    three          ClusterRole/cluster-admin   verbs=[*] apiGroups=[*] resources=[*]
+                                                                verbs=[*] nonResourceURLs=[*]
+circleci:circleci    two            ClusterRole/cluster-admin   verbs=[*] apiGroups=[*] resources=[*]
+                                                                verbs=[*] nonResourceURLs=[*]
+joe                  cluster-wide   ClusterRole/bar             <none>
+joe                  foo            Role/bar                    verbs=[get list] apiGroups=[""] resources=[pods]
+sue                  cluster-wide   ClusterRole/bar             <none>
+sue                  foo            Role/bar                    verbs=[get list] apiGroups=[""] resources=[pods]
+`, buf.String())
+}
+
+func TestAggregatedRules(t *testing.T) {
+	l := genLister()
+	l.showRules = true
+
+	createClusterRoleBindings(t, l)
+
+	clusterRoles := []rbacv1.ClusterRole{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "bar",
+		},
+		AggregationRule: &rbacv1.AggregationRule{
+			ClusterRoleSelectors: []metav1.LabelSelector{{
+				MatchLabels: map[string]string{"aggregate-to-bar": "true"},
+			}},
+		},
+		Rules: []rbacv1.PolicyRule{{
+			Verbs:     []string{"get"},
+			APIGroups: []string{""},
+			Resources: []string{"pods"},
+		}},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "bar-pods",
+			Labels: map[string]string{"aggregate-to-bar": "true"},
+		},
+		Rules: []rbacv1.PolicyRule{{
+			Verbs:     []string{"get"},
+			APIGroups: []string{""},
+			Resources: []string{"pods"},
+		}},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "bar-secrets",
+			Labels: map[string]string{"aggregate-to-bar": "true"},
+		},
+		Rules: []rbacv1.PolicyRule{{
+			Verbs:     []string{"get"},
+			APIGroups: []string{""},
+			Resources: []string{"pods"},
+		}, {
+			Verbs:     []string{"get"},
+			APIGroups: []string{""},
+			Resources: []string{"secrets"},
+		}},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "unrelated",
+			Labels: map[string]string{"aggregate-to-baz": "true"},
+		},
+		Rules: []rbacv1.PolicyRule{{
+			Verbs:     []string{"*"},
+			APIGroups: []string{"*"},
+			Resources: []string{"*"},
+		}},
+	}}
+
+	for _, clusterRole := range clusterRoles {
+		_, err := l.clientset.RbacV1().ClusterRoles().Create(context.Background(), &clusterRole, metav1.CreateOptions{})
+		assert.Nil(t, err, "Expected no error creating cluster roles")
+	}
+
+	loadAll(t, l)
+
+	sr := l.rbacSubjectsByScope["joe"].RolesByScope["cluster-wide"][0]
+	assert.True(t, sr.Aggregated, "Expected ClusterRole/bar to be aggregated")
+	assert.EqualValues(t, []roleRule{{
+		PolicyRule: rbacv1.PolicyRule{
+			Verbs:     []string{"get"},
+			APIGroups: []string{""},
+			Resources: []string{"pods"},
+		},
+		AggregatedFrom: "ClusterRole/bar-pods",
+	}, {
+		PolicyRule: rbacv1.PolicyRule{
+			Verbs:     []string{"get"},
+			APIGroups: []string{""},
+			Resources: []string{"secrets"},
+		},
+		AggregatedFrom: "ClusterRole/bar-secrets",
+	}}, sr.Rules)
+
+	delete(l.rbacSubjectsByScope, "circleci:circleci")
+
+	var buf bytes.Buffer
+	err := l.printRbacBindings(&buf, "wide")
+	assert.Nil(t, err, "Expected no error printing")
+	assert.Equal(t, `SUBJECT     SCOPE          ROLE                           SOURCE                       RULES
+User/joe    cluster-wide   ClusterRole/bar (aggregated)   ClusterRoleBinding/testing   verbs=[get] apiGroups=[""] resources=[pods] (from ClusterRole/bar-pods)
+                                                                                       verbs=[get] apiGroups=[""] resources=[secrets] (from ClusterRole/bar-secrets)
+User/sue    cluster-wide   ClusterRole/bar (aggregated)   ClusterRoleBinding/testing   verbs=[get] apiGroups=[""] resources=[pods] (from ClusterRole/bar-pods)
+                                                                                       verbs=[get] apiGroups=[""] resources=[secrets] (from ClusterRole/bar-secrets)
+`, buf.String())
+}
+
+func TestCheckRefs(t *testing.T) {
+	l := genLister()
+	l.checkRefs = true
+	l.rbacSubjectsByScope["jane@example.com"] = rbacSubject{
+		Kind: "User",
+		RolesByScope: map[string][]simpleRole{
+			"project-wide": {{
+				Kind: "IAM",
+				Name: "gke-admin",
+				Source: simpleRoleSource{
+					Kind: "IAMRole",
+					Name: "container.admin",
+				},
+			}},
+		},
+	}
+
+	createRoleBindings(t, l)
+	createClusterRoleBindings(t, l)
+	createRoles(t, l)
+
+	loadAll(t, l)
+
+	assert.False(t, l.rbacSubjectsByScope["joe"].RolesByScope["foo"][0].Missing, "Expected Role/bar to exist")
+	assert.True(t, l.rbacSubjectsByScope["joe"].RolesByScope["cluster-wide"][0].Missing, "Expected ClusterRole/bar to be missing")
+	assert.False(t, l.rbacSubjectsByScope["circleci:circleci"].RolesByScope["two"][0].Missing, "Expected ClusterRole/cluster-admin to exist")
+	assert.Nil(t, l.rbacSubjectsByScope["joe"].RolesByScope["foo"][0].Rules, "Expected no rules without --show-rules")
+
+	var buf bytes.Buffer
+	err := l.printRbacBindings(&buf, "wide")
+	assert.Nil(t, err, "Expected no error printing")
+	assert.Equal(t, `SUBJECT                             SCOPE          ROLE                        SOURCE                                      REF
+ServiceAccount/circleci:circleci    cluster-wide   ClusterRole/cluster-admin   ClusterRoleBinding/circleci-cluster-admin   ok
+ServiceAccount/circleci:circleci    three          ClusterRole/cluster-admin   RoleBinding/testing-sa                      ok
+ServiceAccount/circleci:circleci    two            ClusterRole/cluster-admin   RoleBinding/testing-sa                      ok
+User/jane@example.com               project-wide   IAM/gke-admin               IAMRole/container.admin                     n/a
+User/joe                            cluster-wide   ClusterRole/bar             ClusterRoleBinding/testing                  missing
+User/joe                            foo            Role/bar                    RoleBinding/testing                         ok
+User/sue                            cluster-wide   ClusterRole/bar             ClusterRoleBinding/testing                  missing
+User/sue                            foo            Role/bar                    RoleBinding/testing                         ok
+`, buf.String())
+
+	buf.Reset()
+	err = l.printRbacBindings(&buf, "")
+	assert.Nil(t, err, "Expected no error printing")
+	assert.Contains(t, buf.String(), "joe                  cluster-wide   ClusterRole/bar (missing)\n")
+}
+
+func TestFormatPolicyRule(t *testing.T) {
+	assert.Equal(t, `verbs=[get] apiGroups=["" apps] resources=[deployments configmaps] resourceNames=[app]`, formatPolicyRule(rbacv1.PolicyRule{
+		Verbs:         []string{"get"},
+		APIGroups:     []string{"", "apps"},
+		Resources:     []string{"deployments", "configmaps"},
+		ResourceNames: []string{"app"},
+	}))
+	assert.Equal(t, "verbs=[get] nonResourceURLs=[/healthz]", formatPolicyRule(rbacv1.PolicyRule{
+		Verbs:           []string{"get"},
+		NonResourceURLs: []string{"/healthz"},
+	}))
+}
+
+func createRoles(t *testing.T, l lister) {
+	roles := []rbacv1.Role{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "bar",
+			Namespace: "foo",
+		},
+		Rules: []rbacv1.PolicyRule{{
+			Verbs:     []string{"get", "list"},
+			APIGroups: []string{""},
+			Resources: []string{"pods"},
+		}},
+	}}
+
+	for _, role := range roles {
+		_, err := l.clientset.RbacV1().Roles(role.Namespace).Create(context.Background(), &role, metav1.CreateOptions{})
+		assert.Nil(t, err, "Expected no error creating roles")
+	}
+
+	clusterRoles := []rbacv1.ClusterRole{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "cluster-admin",
+		},
+		Rules: []rbacv1.PolicyRule{{
+			Verbs:     []string{"*"},
+			APIGroups: []string{"*"},
+			Resources: []string{"*"},
+		}, {
+			Verbs:           []string{"*"},
+			NonResourceURLs: []string{"*"},
+		}},
+	}}
+
+	for _, clusterRole := range clusterRoles {
+		_, err := l.clientset.RbacV1().ClusterRoles().Create(context.Background(), &clusterRole, metav1.CreateOptions{})
+		assert.Nil(t, err, "Expected no error creating cluster roles")
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2018 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lookup
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var sortKeys = []string{"subject", "scope", "role", "source"}
+
+// ValidateSortBy returns an error listing the supported sort keys if sortBy
+// is not one of them. An empty key means the default ordering.
+func ValidateSortBy(sortBy string) error {
+	if sortBy == "" {
+		return nil
+	}
+
+	for _, k := range sortKeys {
+		if sortBy == k {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unsupported sort key %q, must be one of: %s", sortBy, strings.Join(sortKeys, ", "))
+}
+
+// unsortedFormats are output formats with an order of their own, which
+// --sort-by doesn't apply to.
+var unsortedFormats = []string{"json", "yaml", "go-template", "go-template-file", "jsonpath", "roles", "bindings"}
+
+// ValidateSortByFormat returns an error if sortBy is given along with an
+// output format it has no effect on, rather than silently ignoring it.
+func ValidateSortByFormat(sortBy, outputFormat string) error {
+	if sortBy == "" {
+		return nil
+	}
+
+	name, _, _ := strings.Cut(outputFormat, "=")
+	for _, f := range unsortedFormats {
+		if name == f {
+			return fmt.Errorf("--sort-by can't be used with output format %s, which has its own order", name)
+		}
+	}
+
+	return nil
+}
+
+// scopeRank puts cluster-wide and project-wide scopes ahead of namespaces.
+func scopeRank(scope string) int {
+	switch scope {
+	case clusterScope:
+		return 0
+	case gkeIamScope:
+		return 1
+	default:
+		return 2
+	}
+}
+
+func scopeLess(a, b string) bool {
+	if scopeRank(a) != scopeRank(b) {
+		return scopeRank(a) < scopeRank(b)
+	}
+	return a < b
+}
+
+func roleLess(a, b simpleRole) bool {
+	if a.Name != b.Name {
+		return a.Name < b.Name
+	}
+	if a.Kind != b.Kind {
+		return a.Kind < b.Kind
+	}
+	return sourceLess(a.Source, b.Source)
+}
+
+func sourceLess(a, b simpleRoleSource) bool {
+	if a.Kind != b.Kind {
+		return a.Kind < b.Kind
+	}
+	if a.Name != b.Name {
+		return a.Name < b.Name
+	}
+	if a.Level != b.Level {
+		return a.Level < b.Level
+	}
+	return a.Via < b.Via
+}
+
+// sortedScopes returns the scopes of rolesByScope in display order.
+func sortedScopes(rolesByScope map[string][]simpleRole) []string {
+	scopes := make([]string, 0, len(rolesByScope))
+	for scope := range rolesByScope {
+		scopes = append(scopes, scope)
+	}
+	sort.Slice(scopes, func(i, j int) bool {
+		return scopeLess(scopes[i], scopes[j])
+	})
+	return scopes
+}
+
+// sortedRoles returns a sorted copy of roles.
+func sortedRoles(roles []simpleRole) []simpleRole {
+	sorted := append([]simpleRole{}, roles...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return roleLess(sorted[i], sorted[j])
+	})
+	return sorted
+}
+
+// sortRows reorders rows by sortBy. Rows are expected in the default
+// subject, scope, role order already, which breaks any ties.
+func sortRows(rows []outputRow, sortBy string) {
+	var less func(a, b outputRow) bool
+
+	switch sortBy {
+	case "scope":
+		less = func(a, b outputRow) bool { return scopeLess(a.Scope, b.Scope) }
+	case "role":
+		less = func(a, b outputRow) bool {
+			if a.Role.Name != b.Role.Name {
+				return a.Role.Name < b.Role.Name
+			}
+			return a.Role.Kind < b.Role.Kind
+		}
+	case "source":
+		less = func(a, b outputRow) bool { return sourceLess(a.Role.Source, b.Role.Source) }
+	default:
+		return
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		return less(rows[i], rows[j])
+	})
+}
@@ -0,0 +1,145 @@
+// Copyright 2018 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lookup
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func genSortLister() lister {
+	l := genLister()
+	l.rbacSubjectsByScope["joe"] = rbacSubject{
+		Kind: "User",
+		RolesByScope: map[string][]simpleRole{
+			"web": {{
+				Kind:   "ClusterRole",
+				Name:   "view",
+				Source: simpleRoleSource{Kind: "RoleBinding", Name: "joe-view"},
+			}, {
+				Kind:   "ClusterRole",
+				Name:   "edit",
+				Source: simpleRoleSource{Kind: "RoleBinding", Name: "joe-edit"},
+			}},
+			"api": {{
+				Kind:   "Role",
+				Name:   "deployer",
+				Source: simpleRoleSource{Kind: "RoleBinding", Name: "deployers"},
+			}},
+			"project-wide": {{
+				Kind:   "IAM",
+				Name:   "gke-viewer",
+				Source: simpleRoleSource{Kind: "IAMRole", Name: "container.viewer"},
+			}},
+			"cluster-wide": {{
+				Kind:   "ClusterRole",
+				Name:   "view",
+				Source: simpleRoleSource{Kind: "ClusterRoleBinding", Name: "all-view"},
+			}},
+		},
+	}
+	l.rbacSubjectsByScope["ann"] = rbacSubject{
+		Kind: "User",
+		RolesByScope: map[string][]simpleRole{
+			"web": {{
+				Kind:   "ClusterRole",
+				Name:   "admin",
+				Source: simpleRoleSource{Kind: "RoleBinding", Name: "ann-admin"},
+			}},
+		},
+	}
+	return l
+}
+
+func TestPrintDefaultOrder(t *testing.T) {
+	l := genSortLister()
+
+	for i := 0; i < 10; i++ {
+		var buf bytes.Buffer
+		err := l.printRbacBindings(&buf, "")
+		assert.Nil(t, err, "Expected no error printing")
+		assert.Equal(t, `SUBJECT   SCOPE          ROLE
+ann       web            ClusterRole/admin
+joe       cluster-wide   ClusterRole/view
+joe       project-wide   IAM/gke-viewer
+joe       api            Role/deployer
+joe       web            ClusterRole/edit
+joe       web            ClusterRole/view
+`, buf.String())
+	}
+}
+
+func TestPrintSortBy(t *testing.T) {
+	l := genSortLister()
+
+	var buf bytes.Buffer
+	l.sortBy = "scope"
+	err := l.printRbacBindings(&buf, "csv")
+	assert.Nil(t, err, "Expected no error printing")
+	assert.Equal(t, `SUBJECT,SUBJECT KIND,SCOPE,ROLE KIND,ROLE NAME,SOURCE KIND,SOURCE NAME,SOURCE LEVEL,VIA,CONDITION
+joe,User,cluster-wide,ClusterRole,view,ClusterRoleBinding,all-view,,,
+joe,User,project-wide,IAM,gke-viewer,IAMRole,container.viewer,,,
+joe,User,api,Role,deployer,RoleBinding,deployers,,,
+ann,User,web,ClusterRole,admin,RoleBinding,ann-admin,,,
+joe,User,web,ClusterRole,edit,RoleBinding,joe-edit,,,
+joe,User,web,ClusterRole,view,RoleBinding,joe-view,,,
+`, buf.String())
+
+	buf.Reset()
+	l.sortBy = "role"
+	err = l.printRbacBindings(&buf, "custom-columns=ROLE:.role.name,SUBJECT:.subject,SCOPE:.scope")
+	assert.Nil(t, err, "Expected no error printing")
+	assert.Equal(t, `ROLE         SUBJECT   SCOPE
+admin        ann       web
+deployer     joe       api
+edit         joe       web
+gke-viewer   joe       project-wide
+view         joe       cluster-wide
+view         joe       web
+`, buf.String())
+
+	buf.Reset()
+	l.sortBy = "source"
+	err = l.printRbacBindings(&buf, "custom-columns=SOURCE:.role.source.name")
+	assert.Nil(t, err, "Expected no error printing")
+	assert.Equal(t, `SOURCE
+all-view
+container.viewer
+ann-admin
+deployers
+joe-edit
+joe-view
+`, buf.String())
+}
+
+func TestValidateSortBy(t *testing.T) {
+	for _, k := range []string{"", "subject", "scope", "role", "source"} {
+		assert.Nil(t, ValidateSortBy(k), "Expected %q to be a valid sort key", k)
+	}
+
+	assert.EqualError(t, ValidateSortBy("name"), `unsupported sort key "name", must be one of: subject, scope, role, source`)
+}
+
+func TestValidateSortByFormat(t *testing.T) {
+	for _, f := range []string{"", "normal", "wide", "csv", "tsv", "custom-columns=NAME:.subject"} {
+		assert.Nil(t, ValidateSortByFormat("scope", f), "Expected --sort-by to be allowed with %q", f)
+	}
+
+	assert.Nil(t, ValidateSortByFormat("", "json"), "Expected no error without --sort-by")
+	assert.EqualError(t, ValidateSortByFormat("scope", "json"), "--sort-by can't be used with output format json, which has its own order")
+	assert.EqualError(t, ValidateSortByFormat("role", "jsonpath={.subjects[*].name}"), "--sort-by can't be used with output format jsonpath, which has its own order")
+}
@@ -0,0 +1,99 @@
+// Copyright 2018 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lookup
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/cli-runtime/pkg/printers"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/kubectl/pkg/cmd/get"
+)
+
+// printTemplate renders a structured output document with kubectl's Go
+// template or JSONPath printer. As with kubectl, templates are evaluated
+// against the JSON representation so field names match the json output, and
+// missing keys are allowed.
+func printTemplate(w io.Writer, format, text string, doc interface{}) error {
+	obj, err := toUnstructured(doc)
+	if err != nil {
+		return err
+	}
+
+	var printer printers.ResourcePrinter
+	switch format {
+	case "go-template-file":
+		contents, err := os.ReadFile(text)
+		if err != nil {
+			return fmt.Errorf("error reading template file %s: %v", text, err)
+		}
+		text = string(contents)
+		fallthrough
+	case "go-template":
+		p, err := printers.NewGoTemplatePrinter([]byte(text))
+		if err != nil {
+			return fmt.Errorf("error parsing template %s: %v", text, err)
+		}
+		p.AllowMissingKeys(true)
+		printer = p
+	default:
+		p, err := printers.NewJSONPathPrinter(text)
+		if err != nil {
+			return fmt.Errorf("error parsing jsonpath %s: %v", text, err)
+		}
+		p.AllowMissingKeys(true)
+		printer = p
+	}
+
+	return printer.PrintObj(obj, w)
+}
+
+// printCustomColumns prints a table from a spec like
+// SUBJECT:.subject,ROLE:.role.name with kubectl's custom-columns printer,
+// with one line per output row.
+func printCustomColumns(w io.Writer, spec string, outputRows []outputRow) error {
+	printer, err := get.NewCustomColumnsPrinterFromSpec(spec, scheme.Codecs.UniversalDecoder(), false)
+	if err != nil {
+		return err
+	}
+
+	list := &unstructured.UnstructuredList{Object: map[string]interface{}{}}
+	for _, row := range outputRows {
+		obj, err := toUnstructured(row)
+		if err != nil {
+			return err
+		}
+		list.Items = append(list.Items, *obj)
+	}
+
+	return printer.PrintObj(list, w)
+}
+
+// toUnstructured converts v into the maps and slices produced by decoding its
+// JSON form, the same shape kubectl evaluates templates against.
+func toUnstructured(v interface{}) (*unstructured.Unstructured, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	obj := &unstructured.Unstructured{}
+	err = json.Unmarshal(b, &obj.Object)
+	return obj, err
+}
@@ -0,0 +1,219 @@
+// Copyright 2018 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lookup
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/restmapper"
+)
+
+type accessRequest struct {
+	Verb           string
+	APIGroup       string
+	AnyAPIGroup    bool
+	Resource       string
+	Subresource    string
+	Name           string
+	NonResourceURL string
+}
+
+// parseAccessRequest accepts resources the way kubectl auth can-i does,
+// TYPE[.GROUP][/NAME] or a non-resource URL starting with a slash. When no
+// group is given, rules for any API group match until resolveAPIGroup finds
+// the resource's group.
+func parseAccessRequest(verb, resource, subresource string) accessRequest {
+	req := accessRequest{
+		Verb:        strings.ToLower(verb),
+		Subresource: subresource,
+	}
+
+	if strings.HasPrefix(resource, "/") {
+		req.NonResourceURL = resource
+		return req
+	}
+
+	resource, req.Name, _ = strings.Cut(resource, "/")
+	req.Resource, req.APIGroup, _ = strings.Cut(strings.ToLower(resource), ".")
+	req.AnyAPIGroup = !strings.Contains(resource, ".")
+
+	return req
+}
+
+// resolveAPIGroup looks up the API group and full name of a resource given
+// without a group through discovery, the way kubectl auth can-i does, so that
+// a resource of the same name in another group doesn't match. Resources that
+// discovery doesn't know about still match rules for any API group.
+func resolveAPIGroup(req accessRequest, client discovery.DiscoveryInterface) accessRequest {
+	if !req.AnyAPIGroup || req.NonResourceURL != "" {
+		return req
+	}
+
+	groupResources, err := restmapper.GetAPIGroupResources(client)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not discover API resources, matching %s in any API group\n", req.Resource)
+		return req
+	}
+
+	mapper := restmapper.NewShortcutExpander(restmapper.NewDiscoveryRESTMapper(groupResources), client)
+	gvr, err := mapper.ResourceFor(schema.GroupVersionResource{Resource: req.Resource})
+	if err != nil {
+		return req
+	}
+
+	req.Resource, req.APIGroup, req.AnyAPIGroup = gvr.Resource, gvr.Group, false
+	return req
+}
+
+// filterByAccess drops every role that does not allow req, keeping only the
+// rules that do, and then any subjects left without roles.
+func (l *lister) filterByAccess(req accessRequest) {
+	for subjectName, rbacSubj := range l.rbacSubjectsByScope {
+		rolesByScope := make(map[string][]simpleRole)
+
+		for scope, simpleRoles := range rbacSubj.RolesByScope {
+			if !req.appliesInScope(scope) {
+				continue
+			}
+
+			for _, sr := range simpleRoles {
+				rules := []roleRule{}
+				for _, rule := range sr.Rules {
+					if rule.CustomResources && !req.forCustomResource() {
+						continue
+					}
+					if req.allowedByRule(rule.PolicyRule) {
+						rules = append(rules, rule)
+					}
+				}
+
+				if len(rules) > 0 {
+					sr.Rules = rules
+					rolesByScope[scope] = append(rolesByScope[scope], sr)
+				}
+			}
+		}
+
+		if len(rolesByScope) == 0 {
+			delete(l.rbacSubjectsByScope, subjectName)
+			continue
+		}
+
+		rbacSubj.RolesByScope = rolesByScope
+		l.rbacSubjectsByScope[subjectName] = rbacSubj
+	}
+}
+
+// appliesInScope reports whether a role bound in scope can grant req.
+// Non-resource URLs can only be granted cluster-wide.
+func (req accessRequest) appliesInScope(scope string) bool {
+	return scope == clusterScope || req.NonResourceURL == ""
+}
+
+func (req accessRequest) allowedByRule(rule rbacv1.PolicyRule) bool {
+	if !containsOrWildcard(rule.Verbs, req.Verb) {
+		return false
+	}
+
+	if req.NonResourceURL != "" {
+		return nonResourceURLMatches(rule.NonResourceURLs, req.NonResourceURL)
+	}
+
+	if !req.AnyAPIGroup && !containsOrWildcard(rule.APIGroups, req.APIGroup) {
+		return false
+	}
+
+	if !resourceMatches(rule.Resources, req.Resource, req.Subresource) {
+		return false
+	}
+
+	if len(rule.ResourceNames) == 0 {
+		return true
+	}
+
+	return req.Name != "" && contains(rule.ResourceNames, req.Name)
+}
+
+// forCustomResource reports whether req may be for a custom resource, which
+// is the case for any resource outside the built-in API groups and for
+// resources whose group isn't known.
+func (req accessRequest) forCustomResource() bool {
+	if req.NonResourceURL != "" {
+		return false
+	}
+	if req.AnyAPIGroup {
+		return true
+	}
+	return !scheme.Scheme.IsGroupRegistered(req.APIGroup) && !builtInAPIGroups[req.APIGroup]
+}
+
+// builtInAPIGroups are the API groups served by the Kubernetes API server
+// itself that aren't registered in the client-go scheme.
+var builtInAPIGroups = map[string]bool{
+	"apiextensions.k8s.io":   true,
+	"apiregistration.k8s.io": true,
+}
+
+func resourceMatches(ruleResources []string, resource, subresource string) bool {
+	combined := resource
+	if subresource != "" {
+		combined = resource + "/" + subresource
+	}
+
+	for _, ruleResource := range ruleResources {
+		if ruleResource == rbacv1.ResourceAll || ruleResource == combined {
+			return true
+		}
+
+		if subresource != "" && ruleResource == "*/"+subresource {
+			return true
+		}
+	}
+
+	return false
+}
+
+func nonResourceURLMatches(ruleURLs []string, url string) bool {
+	for _, ruleURL := range ruleURLs {
+		if ruleURL == rbacv1.NonResourceAll || ruleURL == url {
+			return true
+		}
+
+		if strings.HasSuffix(ruleURL, "*") && strings.HasPrefix(url, strings.TrimSuffix(ruleURL, "*")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsOrWildcard(values []string, value string) bool {
+	return contains(values, "*") || contains(values, value)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,188 @@
+// Copyright 2018 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lookup
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseAccessRequest(t *testing.T) {
+	assert.Equal(t, accessRequest{
+		Verb:        "delete",
+		Resource:    "secrets",
+		AnyAPIGroup: true,
+	}, parseAccessRequest("DELETE", "secrets", ""))
+
+	assert.Equal(t, accessRequest{
+		Verb:     "update",
+		Resource: "deployments",
+		APIGroup: "apps",
+		Name:     "web",
+	}, parseAccessRequest("update", "deployments.apps/web", ""))
+
+	assert.Equal(t, accessRequest{
+		Verb:        "get",
+		Resource:    "pods",
+		AnyAPIGroup: true,
+		Subresource: "log",
+	}, parseAccessRequest("get", "pods", "log"))
+
+	assert.Equal(t, accessRequest{
+		Verb:           "get",
+		NonResourceURL: "/metrics",
+	}, parseAccessRequest("get", "/metrics", ""))
+}
+
+func TestResolveAPIGroup(t *testing.T) {
+	clientset := testclient.NewSimpleClientset()
+	clientset.Resources = []*metav1.APIResourceList{{
+		GroupVersion: "v1",
+		APIResources: []metav1.APIResource{{Name: "secrets", Kind: "Secret", Namespaced: true}},
+	}, {
+		GroupVersion: "apps/v1",
+		APIResources: []metav1.APIResource{{Name: "deployments", Kind: "Deployment", Namespaced: true, ShortNames: []string{"deploy"}}},
+	}, {
+		GroupVersion: "vault.example.com/v1",
+		APIResources: []metav1.APIResource{{Name: "vaultsecrets", Kind: "VaultSecret", Namespaced: true}},
+	}}
+
+	req := resolveAPIGroup(parseAccessRequest("delete", "secrets", ""), clientset.Discovery())
+	assert.Equal(t, accessRequest{Verb: "delete", Resource: "secrets"}, req)
+
+	req = resolveAPIGroup(parseAccessRequest("update", "deploy/web", "scale"), clientset.Discovery())
+	assert.Equal(t, accessRequest{Verb: "update", Resource: "deployments", APIGroup: "apps", Name: "web", Subresource: "scale"}, req)
+
+	req = resolveAPIGroup(parseAccessRequest("get", "widgets", ""), clientset.Discovery())
+	assert.True(t, req.AnyAPIGroup, "Expected an unknown resource to match any API group")
+
+	customSecrets := rbacv1.PolicyRule{
+		Verbs:     []string{"delete"},
+		APIGroups: []string{"vault.example.com"},
+		Resources: []string{"secrets"},
+	}
+	req = resolveAPIGroup(parseAccessRequest("delete", "secrets", ""), clientset.Discovery())
+	assert.False(t, req.allowedByRule(customSecrets), "Expected secrets in another API group not to match")
+}
+
+func TestAllowedByRule(t *testing.T) {
+	secretsReader := rbacv1.PolicyRule{
+		Verbs:     []string{"get", "list"},
+		APIGroups: []string{""},
+		Resources: []string{"secrets"},
+	}
+	namedSecret := rbacv1.PolicyRule{
+		Verbs:         []string{"delete"},
+		APIGroups:     []string{""},
+		Resources:     []string{"secrets"},
+		ResourceNames: []string{"db-password"},
+	}
+	everything := rbacv1.PolicyRule{
+		Verbs:     []string{"*"},
+		APIGroups: []string{"*"},
+		Resources: []string{"*"},
+	}
+	scaler := rbacv1.PolicyRule{
+		Verbs:     []string{"update"},
+		APIGroups: []string{"apps"},
+		Resources: []string{"*/scale"},
+	}
+	healthz := rbacv1.PolicyRule{
+		Verbs:           []string{"get"},
+		NonResourceURLs: []string{"/healthz", "/livez/*"},
+	}
+
+	cases := []struct {
+		req      accessRequest
+		rule     rbacv1.PolicyRule
+		expected bool
+	}{
+		{parseAccessRequest("get", "secrets", ""), secretsReader, true},
+		{parseAccessRequest("delete", "secrets", ""), secretsReader, false},
+		{parseAccessRequest("get", "configmaps", ""), secretsReader, false},
+		{parseAccessRequest("get", "secrets.apps", ""), secretsReader, false},
+		{parseAccessRequest("delete", "secrets", ""), namedSecret, false},
+		{parseAccessRequest("delete", "secrets/db-password", ""), namedSecret, true},
+		{parseAccessRequest("delete", "secrets/other", ""), namedSecret, false},
+		{parseAccessRequest("delete", "secrets", ""), everything, true},
+		{parseAccessRequest("create", "deployments.apps", ""), everything, true},
+		{parseAccessRequest("get", "pods", "log"), everything, true},
+		{parseAccessRequest("get", "/healthz", ""), everything, false},
+		{parseAccessRequest("update", "deployments.apps", "scale"), scaler, true},
+		{parseAccessRequest("update", "deployments.apps", ""), scaler, false},
+		{parseAccessRequest("get", "/healthz", ""), healthz, true},
+		{parseAccessRequest("get", "/livez/ping", ""), healthz, true},
+		{parseAccessRequest("get", "/readyz", ""), healthz, false},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.expected, c.req.allowedByRule(c.rule), "Unexpected result for %+v with rule %s", c.req, formatPolicyRule(c.rule))
+	}
+}
+
+func TestForCustomResource(t *testing.T) {
+	assert.True(t, parseAccessRequest("delete", "certificates.cert-manager.io", "").forCustomResource())
+	assert.True(t, parseAccessRequest("delete", "certificates", "").forCustomResource(), "Expected a resource without a known group to possibly be custom")
+	assert.False(t, parseAccessRequest("delete", "deployments.apps", "").forCustomResource())
+	assert.False(t, parseAccessRequest("create", "customresourcedefinitions.apiextensions.k8s.io", "").forCustomResource())
+	assert.False(t, parseAccessRequest("get", "/metrics", "").forCustomResource())
+}
+
+func TestFilterByAccess(t *testing.T) {
+	l := genLister()
+	l.showRules = true
+	l.namespaceFilter = []string{"foo"}
+
+	createRoleBindings(t, l)
+	createClusterRoleBindings(t, l)
+	createRoles(t, l)
+
+	loadAll(t, l)
+
+	l.filterByAccess(parseAccessRequest("list", "pods", ""))
+
+	assert.Len(t, l.rbacSubjectsByScope, 3, "Expected 3 rbac subjects")
+	assert.EqualValues(t, rbacSubject{
+		Kind: "ServiceAccount",
+		RolesByScope: map[string][]simpleRole{
+			"cluster-wide": {{
+				Kind: "ClusterRole",
+				Name: "cluster-admin",
+				Source: simpleRoleSource{
+					Kind: "ClusterRoleBinding",
+					Name: "circleci-cluster-admin",
+				},
+				Rules: []roleRule{{
+					PolicyRule: rbacv1.PolicyRule{
+						Verbs:     []string{"*"},
+						APIGroups: []string{"*"},
+						Resources: []string{"*"},
+					},
+				}},
+			}},
+		},
+	}, l.rbacSubjectsByScope["circleci:circleci"])
+	assert.Len(t, l.rbacSubjectsByScope["joe"].RolesByScope, 1)
+	assert.Len(t, l.rbacSubjectsByScope["joe"].RolesByScope["foo"], 1)
+
+	l.filterByAccess(parseAccessRequest("delete", "pods", ""))
+
+	assert.Len(t, l.rbacSubjectsByScope, 1, "Expected only cluster-admin to delete pods")
+}
@@ -0,0 +1,219 @@
+// Copyright 2018 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lookup
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"google.golang.org/api/iam/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+const (
+	workloadIdentityAnnotation = "iam.gke.io/gcp-service-account"
+	workloadIdentityUserRole   = "roles/iam.workloadIdentityUser"
+)
+
+// workloadIdentity is a Kubernetes ServiceAccount allowed to impersonate a
+// Google service account through GKE Workload Identity.
+type workloadIdentity struct {
+	// ServiceAccount is the Kubernetes ServiceAccount as namespace:name
+	ServiceAccount string
+	// GSA is the email of the Google service account
+	GSA string
+	// Annotated is set if the ServiceAccount is annotated with the GSA, so
+	// pods running as it act as the GSA
+	Annotated bool
+	// Grant is the workloadIdentityUser role allowing the impersonation
+	Grant simpleRole
+}
+
+// loadWorkloadIdentities finds the Google service accounts that
+// ServiceAccounts are annotated with, and which ServiceAccounts each of
+// them can be impersonated by. Only ServiceAccounts in the cluster's own
+// workload pool, PROJECT.svc.id.goog, are included. If ServiceAccounts
+// can't be listed, Workload Identity is skipped.
+func (l *lister) loadWorkloadIdentities(iamService *iam.Service) error {
+	if err := l.loadServiceAccountsOnce("Workload Identity"); err != nil {
+		return err
+	}
+
+	annotated := make(map[string]string)
+	emails := []string{}
+	for key, serviceAccount := range l.serviceAccounts {
+		email := serviceAccount.Annotations[workloadIdentityAnnotation]
+		if email == "" {
+			continue
+		}
+
+		annotated[key] = email
+		if !contains(emails, email) {
+			emails = append(emails, email)
+		}
+	}
+
+	if len(emails) == 0 {
+		return nil
+	}
+	sort.Strings(emails)
+
+	policies := loadServiceAccountPolicies(context.Background(), iamService, emails)
+
+	// The first policy is always the cluster's project
+	pool := strings.TrimPrefix(l.gkePolicies[0].Level, "projects/") + ".svc.id.goog"
+	l.workloadIdentities = workloadIdentities(annotated, policies, pool)
+
+	return nil
+}
+
+// workloadIdentities returns every ServiceAccount in the workload pool that
+// is granted roles/iam.workloadIdentityUser on one of the Google service
+// accounts, noting whether it is annotated with it.
+func workloadIdentities(annotated map[string]string, policies map[string]*iam.Policy, pool string) []workloadIdentity {
+	emails := make([]string, 0, len(policies))
+	for email := range policies {
+		emails = append(emails, email)
+	}
+	sort.Strings(emails)
+
+	identities := []workloadIdentity{}
+	for _, email := range emails {
+		for _, binding := range policies[email].Bindings {
+			if binding.Role != workloadIdentityUserRole {
+				continue
+			}
+
+			grant := simpleRole{
+				Kind: "IAM",
+				Name: strings.TrimPrefix(workloadIdentityUserRole, "roles/"),
+				Source: simpleRoleSource{
+					Kind:  "IAMRole",
+					Name:  strings.TrimPrefix(workloadIdentityUserRole, "roles/"),
+					Level: "serviceAccounts/" + email,
+				},
+			}
+			if binding.Condition != nil {
+				grant.Condition = &iamCondition{
+					Title:       binding.Condition.Title,
+					Description: binding.Condition.Description,
+					Expression:  binding.Condition.Expression,
+				}
+			}
+
+			for _, m := range binding.Members {
+				member, ok := parseIamMember(m)
+				if !ok || member.Deleted || member.Kind != rbacv1.ServiceAccountKind {
+					continue
+				}
+
+				serviceAccount, ok := workloadIdentityMember(member.Name, pool)
+				if !ok {
+					continue
+				}
+
+				identities = append(identities, workloadIdentity{
+					ServiceAccount: serviceAccount,
+					GSA:            email,
+					Annotated:      annotated[serviceAccount] == email,
+					Grant:          grant,
+				})
+			}
+		}
+	}
+
+	return identities
+}
+
+// workloadIdentityMember converts a Workload Identity member name such as
+// example.svc.id.goog[web/frontend] into a ServiceAccount key, web:frontend,
+// if it is in the given workload pool.
+func workloadIdentityMember(name, pool string) (string, bool) {
+	if !strings.HasPrefix(name, pool+"[") || !strings.HasSuffix(name, "]") {
+		return "", false
+	}
+
+	namespace, serviceAccount, ok := strings.Cut(strings.TrimSuffix(strings.TrimPrefix(name, pool+"["), "]"), "/")
+	if !ok || namespace == "" || serviceAccount == "" {
+		return "", false
+	}
+
+	return fmt.Sprintf("%s:%s", namespace, serviceAccount), true
+}
+
+// gkeRolesFor returns every GKE and GCP role an IAM member is granted
+// across the project, folder and organization policies.
+func (l *lister) gkeRolesFor(member string) []simpleRole {
+	roles := []simpleRole{}
+	for _, policy := range l.gkePolicies {
+		for _, binding := range policy.Bindings {
+			if !contains(binding.Members, member) {
+				continue
+			}
+			if sr, ok := l.grantedGkeRole(policy, binding); ok {
+				roles = append(roles, sr)
+			}
+		}
+	}
+	return roles
+}
+
+// addWorkloadIdentityRoles adds the workloadIdentityUser grants of
+// ServiceAccounts matching the query, either by their own name without the
+// namespace or by the Google service account they can impersonate.
+// ServiceAccounts annotated with the Google service account also get its
+// roles, with it recorded as the source's Via.
+func (l *lister) addWorkloadIdentityRoles() {
+	for _, wi := range l.workloadIdentities {
+		namespace, name, _ := strings.Cut(wi.ServiceAccount, ":")
+		if len(l.namespaceFilter) > 0 && !contains(l.namespaceFilter, namespace) {
+			continue
+		}
+
+		// ServiceAccounts are matched by name like the RBAC loaders do
+		if !(l.nameMatches(name) || l.nameMatches(wi.GSA)) || !l.kindMatches(rbacv1.ServiceAccountKind) || l.subjectExcluded(wi.ServiceAccount) {
+			continue
+		}
+
+		roles := []simpleRole{}
+		if l.roleMatches(wi.Grant.Kind, wi.Grant.Name) {
+			roles = append(roles, wi.Grant)
+		}
+
+		if wi.Annotated {
+			for _, sr := range l.gkeRolesFor("serviceAccount:" + wi.GSA) {
+				sr.Source.Via = wi.GSA
+				roles = append(roles, sr)
+			}
+		}
+
+		if len(roles) == 0 {
+			continue
+		}
+
+		rbacSubj, exist := l.rbacSubjectsByScope[wi.ServiceAccount]
+		if !exist {
+			rbacSubj = rbacSubject{
+				Kind:         rbacv1.ServiceAccountKind,
+				RolesByScope: make(map[string][]simpleRole),
+			}
+		}
+
+		rbacSubj.RolesByScope[gkeIamScope] = append(rbacSubj.RolesByScope[gkeIamScope], roles...)
+		l.rbacSubjectsByScope[wi.ServiceAccount] = rbacSubj
+	}
+}
@@ -0,0 +1,225 @@
+// Copyright 2018 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lookup
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/api/cloudresourcemanager/v1"
+	"google.golang.org/api/iam/v1"
+	"google.golang.org/api/option"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const deployerGSA = "deployer@example.iam.gserviceaccount.com"
+
+func TestWorkloadIdentityMember(t *testing.T) {
+	serviceAccount, ok := workloadIdentityMember("example.svc.id.goog[web/frontend]", "example.svc.id.goog")
+	assert.True(t, ok)
+	assert.Equal(t, "web:frontend", serviceAccount)
+
+	_, ok = workloadIdentityMember("other.svc.id.goog[web/frontend]", "example.svc.id.goog")
+	assert.False(t, ok, "Expected members of another workload pool to be left out")
+
+	_, ok = workloadIdentityMember("example.svc.id.goog[web]", "example.svc.id.goog")
+	assert.False(t, ok, "Expected members without a namespace to be left out")
+
+	_, ok = workloadIdentityMember("ci@example.iam.gserviceaccount.com", "example.svc.id.goog")
+	assert.False(t, ok)
+}
+
+func TestWorkloadIdentities(t *testing.T) {
+	policies := map[string]*iam.Policy{
+		deployerGSA: {
+			Bindings: []*iam.Binding{{
+				Role: "roles/iam.workloadIdentityUser",
+				Members: []string{
+					"serviceAccount:example.svc.id.goog[ci/deployer]",
+					"serviceAccount:example.svc.id.goog[web/frontend]",
+					"serviceAccount:other.svc.id.goog[ci/deployer]",
+					"deleted:serviceAccount:example.svc.id.goog[ci/old]?uid=42",
+					"user:jane@example.com",
+				},
+			}, {
+				Role:    "roles/iam.serviceAccountTokenCreator",
+				Members: []string{"serviceAccount:example.svc.id.goog[ci/builder]"},
+			}},
+		},
+	}
+	annotated := map[string]string{
+		"ci:deployer":  deployerGSA,
+		"web:frontend": "other@example.iam.gserviceaccount.com",
+	}
+
+	grant := simpleRole{
+		Kind: "IAM",
+		Name: "iam.workloadIdentityUser",
+		Source: simpleRoleSource{
+			Kind:  "IAMRole",
+			Name:  "iam.workloadIdentityUser",
+			Level: "serviceAccounts/" + deployerGSA,
+		},
+	}
+
+	assert.Equal(t, []workloadIdentity{{
+		ServiceAccount: "ci:deployer",
+		GSA:            deployerGSA,
+		Annotated:      true,
+		Grant:          grant,
+	}, {
+		ServiceAccount: "web:frontend",
+		GSA:            deployerGSA,
+		Annotated:      false,
+		Grant:          grant,
+	}}, workloadIdentities(annotated, policies, "example.svc.id.goog"))
+}
+
+func genWorkloadIdentityLister() lister {
+	l := genLister()
+	l.gkePolicies = []gkeIamPolicy{{
+		Level: "projects/example",
+		Bindings: []*cloudresourcemanager.Binding{{
+			Role:    "roles/container.developer",
+			Members: []string{"serviceAccount:" + deployerGSA},
+		}, {
+			Role:    "roles/container.viewer",
+			Members: []string{"user:jane@example.com"},
+		}},
+	}}
+
+	grant := simpleRole{
+		Kind: "IAM",
+		Name: "iam.workloadIdentityUser",
+		Source: simpleRoleSource{
+			Kind:  "IAMRole",
+			Name:  "iam.workloadIdentityUser",
+			Level: "serviceAccounts/" + deployerGSA,
+		},
+	}
+	l.workloadIdentities = []workloadIdentity{
+		{ServiceAccount: "ci:deployer", GSA: deployerGSA, Annotated: true, Grant: grant},
+		{ServiceAccount: "web:frontend", GSA: deployerGSA, Annotated: false, Grant: grant},
+	}
+
+	return l
+}
+
+func TestAddWorkloadIdentityRoles(t *testing.T) {
+	l := genWorkloadIdentityLister()
+	l.matcher = &nameMatcher{query: "deployer", mode: "exact"}
+
+	for _, policy := range l.gkePolicies {
+		l.loadGkeIamPolicy(policy)
+	}
+	l.addWorkloadIdentityRoles()
+
+	assert.Len(t, l.rbacSubjectsByScope, 1, "Expected only the matching ServiceAccount")
+	assert.Equal(t, rbacSubject{
+		Kind: "ServiceAccount",
+		RolesByScope: map[string][]simpleRole{
+			gkeIamScope: {{
+				Kind: "IAM",
+				Name: "iam.workloadIdentityUser",
+				Source: simpleRoleSource{
+					Kind:  "IAMRole",
+					Name:  "iam.workloadIdentityUser",
+					Level: "serviceAccounts/" + deployerGSA,
+				},
+			}, {
+				Kind: "IAM",
+				Name: "gke-developer",
+				Source: simpleRoleSource{
+					Kind:  "IAMRole",
+					Name:  "container.developer",
+					Level: "projects/example",
+					Via:   deployerGSA,
+				},
+			}},
+		},
+	}, l.rbacSubjectsByScope["ci:deployer"])
+}
+
+func TestAddWorkloadIdentityRolesByGSA(t *testing.T) {
+	l := genWorkloadIdentityLister()
+	l.matcher = &nameMatcher{query: "deployer@"}
+
+	for _, policy := range l.gkePolicies {
+		l.loadGkeIamPolicy(policy)
+	}
+	l.addWorkloadIdentityRoles()
+
+	var buf bytes.Buffer
+	assert.NoError(t, l.printRbacBindings(&buf, "wide"))
+
+	expected := `SUBJECT                                                    SCOPE          ROLE                           SOURCE
+ServiceAccount/ci:deployer                                 project-wide   IAM/gke-developer              IAMRole/container.developer on projects/example (via deployer@example.iam.gserviceaccount.com)
+ServiceAccount/ci:deployer                                 project-wide   IAM/iam.workloadIdentityUser   IAMRole/iam.workloadIdentityUser on serviceAccounts/deployer@example.iam.gserviceaccount.com
+ServiceAccount/deployer@example.iam.gserviceaccount.com    project-wide   IAM/gke-developer              IAMRole/container.developer on projects/example
+ServiceAccount/web:frontend                                project-wide   IAM/iam.workloadIdentityUser   IAMRole/iam.workloadIdentityUser on serviceAccounts/deployer@example.iam.gserviceaccount.com
+`
+	assert.Equal(t, expected, buf.String())
+
+	l = genWorkloadIdentityLister()
+	l.matcher = &nameMatcher{query: "deployer@"}
+	l.namespaceFilter = []string{"web"}
+	l.addWorkloadIdentityRoles()
+
+	assert.Len(t, l.rbacSubjectsByScope, 1, "Expected ServiceAccounts outside the namespace filter to be left out")
+	assert.Contains(t, l.rbacSubjectsByScope, "web:frontend")
+}
+
+func TestLoadServiceAccountPolicies(t *testing.T) {
+	versions := map[string]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		versions[r.URL.Path] = r.URL.Query().Get("options.requestedPolicyVersion")
+		if r.URL.Path != "/v1/projects/-/serviceAccounts/"+deployerGSA+":getIamPolicy" {
+			http.Error(w, `{"error": {"code": 403, "message": "denied"}}`, http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, `{"bindings": [{"role": "roles/iam.workloadIdentityUser", "members": ["serviceAccount:example.svc.id.goog[ci/deployer]"]}]}`)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	iamService, err := iam.NewService(ctx, option.WithEndpoint(srv.URL+"/"), option.WithHTTPClient(srv.Client()))
+	assert.NoError(t, err)
+
+	policies := loadServiceAccountPolicies(ctx, iamService, []string{deployerGSA, "private@example.iam.gserviceaccount.com"})
+
+	assert.Len(t, policies, 1, "Expected service accounts whose policy can't be read to be skipped")
+	assert.Equal(t, "roles/iam.workloadIdentityUser", policies[deployerGSA].Bindings[0].Role)
+	assert.Equal(t, "3", versions["/v1/projects/-/serviceAccounts/"+deployerGSA+":getIamPolicy"], "Expected policy version 3 to be requested")
+}
+
+func TestLoadWorkloadIdentitiesForbidden(t *testing.T) {
+	l := genLister()
+	l.clientset.(*testclient.Clientset).PrependReactor("list", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(corev1.Resource("serviceaccounts"), "", nil)
+	})
+
+	assert.Nil(t, l.loadWorkloadIdentities(nil), "Expected Workload Identity to be skipped when ServiceAccounts can't be listed")
+	assert.Empty(t, l.workloadIdentities)
+}